@@ -0,0 +1,7 @@
+package main
+
+import assembler "nand2tetris/05.assembler"
+
+func main() {
+	assembler.Main()
+}
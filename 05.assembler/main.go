@@ -1,4 +1,4 @@
-package main
+package assembler
 
 import (
 	"bufio"
@@ -13,6 +13,7 @@ type Assembler struct {
     file          string
     fileName      string
     parsedContent []string
+    lineNumbers   []int
     code          []string
     nextAddress   int
     symbolTable   map[string]int
@@ -75,26 +76,35 @@ func (a *Assembler) readFile() error {
     return nil
 }
 
+// parse drops comments, including those after an instruction, and blank
+// lines, and remembers the source line of each instruction for errors.
 func (a *Assembler) parse() error {
-    if err := a.readFile(); err != nil {
-        return err
-    }
-
     if a.file == "" {
         return fmt.Errorf("parse: File wasn't read properly")
     }
 
     lines := strings.Split(a.file, "\n")
-    for _, line := range lines {
+    for i, line := range lines {
+        if commentIdx := strings.Index(line, "//"); commentIdx != -1 {
+            line = line[:commentIdx]
+        }
         line = strings.TrimSpace(line)
-        if line != "" && !strings.HasPrefix(line, "//") {
+        if line != "" {
             a.parsedContent = append(a.parsedContent, line)
+            a.lineNumbers = append(a.lineNumbers, i+1)
         }
     }
     return nil
 }
 
+func (a *Assembler) errorf(index int, format string, args ...any) error {
+    return fmt.Errorf("%s:%d: %s", a.fileName, a.lineNumbers[index], fmt.Sprintf(format, args...))
+}
+
 func (a *Assembler) writeFile() error {
+    if err := a.readFile(); err != nil {
+        return err
+    }
     if err := a.translate(); err != nil {
         return err
     }
@@ -118,7 +128,9 @@ func (a *Assembler) decodeAInstruction(line string) string {
     address := line[1:]
     var code int
 
-    if n, err := strconv.Atoi(address); err != nil {
+    if address == "" {
+        return ""
+    } else if n, err := strconv.Atoi(address); err != nil {
         if val, exists := a.symbolTable[address]; exists {
             code = val
         } else {
@@ -126,6 +138,8 @@ func (a *Assembler) decodeAInstruction(line string) string {
             code = a.nextAddress
             a.nextAddress++
         }
+    } else if n < 0 || n > 32767 {
+        return ""
     } else {
         code = n
     }
@@ -134,16 +148,20 @@ func (a *Assembler) decodeAInstruction(line string) string {
     return "0" + binary
 }
 
-func (a *Assembler) assignLabelAddress() {
+func (a *Assembler) assignLabelAddress() error {
     lCounter := 0
     for i, line := range a.parsedContent {
         if strings.HasPrefix(line, "(") {
+            if !strings.HasSuffix(line, ")") || len(line) < 3 {
+                return a.errorf(i, "malformed label %s", line)
+            }
             label := line[1 : len(line)-1]
             address := i - lCounter
             a.symbolTable[label] = address
             lCounter++
         }
     }
+    return nil
 }
 
 func (a *Assembler) dest(dest string) string {
@@ -158,10 +176,7 @@ func (a *Assembler) dest(dest string) string {
         "AMD": "111",
     }
 
-    if val, exists := destMap[dest]; exists {
-        return val
-    }
-    return "000"
+    return destMap[dest]
 }
 
 func (a *Assembler) comp(comp string) string {
@@ -184,6 +199,9 @@ func (a *Assembler) comp(comp string) string {
         "A-D": "0000111",
         "D&A": "0000000",
         "D|A": "0010101",
+        "A+D": "0000010",
+        "A&D": "0000000",
+        "A|D": "0010101",
         "M":   "1110000",
         "!M":  "1110001",
         "-M":  "1110011",
@@ -194,12 +212,12 @@ func (a *Assembler) comp(comp string) string {
         "M-D": "1000111",
         "D&M": "1000000",
         "D|M": "1010101",
+        "M+D": "1000010",
+        "M&D": "1000000",
+        "M|D": "1010101",
     }
 
-    if val, exists := compMap[comp]; exists {
-        return val
-    }
-    return "0000000"
+    return compMap[comp]
 }
 
 func (a *Assembler) jump(jump string) string {
@@ -214,10 +232,7 @@ func (a *Assembler) jump(jump string) string {
         "JMP": "111",
     }
 
-    if val, exists := jumpMap[jump]; exists {
-        return val
-    }
-    return "000"
+    return jumpMap[jump]
 }
 
 func (a *Assembler) translate() error {
@@ -229,15 +244,18 @@ func (a *Assembler) translate() error {
         return fmt.Errorf("no content to translate")
     }
 
-    a.assignLabelAddress()
+    if err := a.assignLabelAddress(); err != nil {
+        return err
+    }
 
-    for _, line := range a.parsedContent {
+    for i, line := range a.parsedContent {
         switch a.instructionType(line) {
         case "A":
             address := a.decodeAInstruction(line)
-            if address != "" {
-                a.code = append(a.code, address)
+            if address == "" {
+                return a.errorf(i, "%s is not an address between 0 and 32767 or a symbol", line)
             }
+            a.code = append(a.code, address)
         case "C":
             if line == "" {
                 continue
@@ -258,24 +276,44 @@ func (a *Assembler) translate() error {
                 parts := strings.Split(line, ";")
                 comp = parts[0]
                 jump = parts[1]
+            } else {
+                comp = line
             }
 
-            code := "111"
-            if comp != "" {
-                code += a.comp(comp)
-            } else {
-                code += "0000000"
+            compBits, destBits, jumpBits := a.comp(comp), a.dest(dest), a.jump(jump)
+            switch {
+            case compBits == "":
+                return a.errorf(i, "unknown computation %q in %s", comp, line)
+            case destBits == "":
+                return a.errorf(i, "unknown destination %q in %s", dest, line)
+            case jumpBits == "":
+                return a.errorf(i, "unknown jump %q in %s", jump, line)
             }
-            code += a.dest(dest)
-            code += a.jump(jump)
-            a.code = append(a.code, code)
+            a.code = append(a.code, "111"+compBits+destBits+jumpBits)
         }
     }
     return nil
 }
 
-func main() {
-    assembler := NewAssembler("Rect.asm")
+// Assemble translates Hack assembly into the lines of a .hack file. The name
+// only labels the source in errors.
+func Assemble(name, source string) (string, error) {
+    a := NewAssembler(name)
+    a.file = source
+    if err := a.translate(); err != nil {
+        return "", err
+    }
+    return strings.Join(a.code, "\n"), nil
+}
+
+// Main assembles the file given as argument, Rect.asm by default, into a
+// .hack file next to it.
+func Main() {
+    fileName := "Rect.asm"
+    if len(os.Args) > 1 {
+        fileName = os.Args[1]
+    }
+    assembler := NewAssembler(fileName)
     if err := assembler.writeFile(); err != nil {
         fmt.Printf("Error: %v\n", err)
         os.Exit(1)
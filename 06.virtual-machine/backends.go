@@ -0,0 +1,212 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// programLayout holds what the non-assembly backends need to know about a
+// whole program before they emit code: where statics live, a numeric id for
+// every label and function, the return point of every call and which labels
+// and functions are ever jumped to.
+type programLayout struct {
+    statics    map[string]int
+    labels     map[string]int
+    functions  map[string]int
+    numLocals  map[string]int
+    returns    map[int]int
+    targets    map[string]bool
+}
+
+// newProgramLayout gives statics addresses from 16 in order of first
+// appearance, which is the order the assembler allocates their symbols in.
+// Calls to builtins are allowed even though the program does not define them.
+func newProgramLayout(lines []string, builtins map[string]bool) (*programLayout, error) {
+    layout := &programLayout{
+        statics:   make(map[string]int),
+        labels:    make(map[string]int),
+        functions: make(map[string]int),
+        numLocals: make(map[string]int),
+        returns:   make(map[int]int),
+        targets:   make(map[string]bool),
+    }
+
+    addStatic := func(file, index string) {
+        name := file + "." + index
+        if _, ok := layout.statics[name]; !ok {
+            layout.statics[name] = 16 + len(layout.statics)
+        }
+    }
+
+    for i, line := range lines {
+        args := strings.Fields(line)
+        switch args[0] {
+        case "push", "pop":
+            if args[1] == "static" {
+                addStatic(fileArg(args, 3), args[2])
+            }
+        case "move":
+            if args[1] == "static" {
+                addStatic(fileArg(args, 5), args[2])
+            }
+            if args[3] == "static" {
+                addStatic(fileArg(args, 5), args[4])
+            }
+        case "label":
+            layout.labels[args[1]] = len(layout.labels)
+        case "function":
+            layout.functions[args[1]] = len(layout.functions)
+            layout.numLocals[args[1]] = parseInt(args[2])
+        case "call":
+            layout.returns[i] = len(layout.returns) + 1
+        }
+    }
+
+    for _, line := range lines {
+        args := strings.Fields(line)
+        switch args[0] {
+        case "goto", "if-goto", "ifnot-goto":
+            if _, ok := layout.labels[args[1]]; !ok {
+                return nil, fmt.Errorf("newProgramLayout: label %s is not defined", args[1])
+            }
+            layout.targets[args[1]] = true
+        case "call":
+            if _, ok := layout.functions[args[1]]; !ok && !builtins[args[1]] {
+                return nil, fmt.Errorf("newProgramLayout: function %s is not defined", args[1])
+            }
+            layout.targets[args[1]] = true
+        }
+    }
+    return layout, nil
+}
+
+func fileArg(args []string, position int) string {
+    if len(args) > position {
+        return args[position]
+    }
+    return ""
+}
+
+// isHaltLoop reports whether lines[i] is the goto of a `label L; goto L`
+// pair, the idiom VM programs use to stop.
+func isHaltLoop(lines []string, i int) bool {
+    args := strings.Fields(lines[i])
+    return args[0] == "goto" && i > 0 && lines[i-1] == "label "+args[1]
+}
+
+func (vm *VMTranslator) writeTarget(target string) error {
+    if target == "asm" {
+        vm.loadBootstrapCode()
+        return vm.writeFile()
+    }
+
+    if err := vm.prepare(); err != nil {
+        return err
+    }
+    fileName := strings.TrimSuffix(vm.fileName, filepath.Ext(vm.fileName))
+
+    switch target {
+    case "c":
+        code, err := vm.generateC(vm.parsedContent)
+        if err != nil {
+            return err
+        }
+        return os.WriteFile(fileName+".c", []byte(code), 0644)
+    case "elf":
+        code, err := vm.generateELF(vm.parsedContent)
+        if err != nil {
+            return err
+        }
+        return os.WriteFile(fileName, code, 0755)
+    case "go":
+        code, err := vm.generateGo(vm.parsedContent)
+        if err != nil {
+            return err
+        }
+        return os.WriteFile(fileName+".go", []byte(code), 0644)
+    case "vmb":
+        code, err := encodeBytecode(vm.parsedContent)
+        if err != nil {
+            return err
+        }
+        return os.WriteFile(fileName+".vmb", code, 0644)
+    case "vm":
+        // Written into a directory of their own so decoding a .vmb next to
+        // its sources never overwrites them.
+        files, err := vmFiles(vm.parsedContent, filepath.Base(fileName))
+        if err != nil {
+            return err
+        }
+        directory := fileName + "-vm"
+        if err := os.MkdirAll(directory, 0755); err != nil {
+            return err
+        }
+        stale, _ := filepath.Glob(filepath.Join(directory, "*.vm"))
+        for _, path := range stale {
+            if err := os.Remove(path); err != nil {
+                return err
+            }
+        }
+        for name, code := range files {
+            if err := os.WriteFile(filepath.Join(directory, name), []byte(code), 0644); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    return fmt.Errorf("writeTarget: unknown target %s", target)
+}
+
+// vmFiles turns parsed commands back into VM source, one .vm file per class.
+// Statics carry their file name as an extra operand once parsed, so each
+// function goes to the file of its class, which must also own every static
+// the function uses, and the file name is dropped from the static operands.
+// Commands before the first function, as in the project 7 tests, go to the
+// file owning their statics, or to name.vm if they use none. Reading the
+// files back gives the same commands.
+func vmFiles(lines []string, name string) (map[string]string, error) {
+    filePosition := func(args []string) int {
+        if args[0] == "move" {
+            return 5
+        }
+        return 3
+    }
+
+    unit := name
+    for _, line := range lines {
+        args := strings.Fields(line)
+        if args[0] == "function" {
+            break
+        }
+        if file := fileArg(args, filePosition(args)); hasStaticOperand(args) && file != "" {
+            unit = file
+            break
+        }
+    }
+
+    units := make(map[string]*strings.Builder)
+    for _, line := range lines {
+        args := strings.Fields(line)
+        if args[0] == "function" {
+            unit, _, _ = strings.Cut(args[1], ".")
+        }
+        if file := fileArg(args, filePosition(args)); hasStaticOperand(args) && file != "" {
+            if file != unit {
+                return nil, fmt.Errorf("vmFiles: %s in %s.vm uses a static of %s.vm, which VM text cannot express", line, unit, file)
+            }
+            line = strings.Join(args[:filePosition(args)], " ")
+        }
+        if units[unit] == nil {
+            units[unit] = &strings.Builder{}
+        }
+        units[unit].WriteString(line + "\n")
+    }
+
+    files := make(map[string]string)
+    for unit, sb := range units {
+        files[unit+".vm"] = sb.String()
+    }
+    return files, nil
+}
@@ -0,0 +1,398 @@
+package vmtranslator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A .vmb file is a 16 byte header followed by a payload of unsigned
+// varints:
+//
+//	magic "HVMB" | version u16 | flags u16 | payload length u32 | CRC-32 u32
+//	strings:   count, then length and bytes of each
+//	functions: count, then name, number of locals and command index of each
+//	commands:  count, then an opcode and its operands for each
+//
+// Labels, function names and static file names are ids into the string
+// table; a static operand carries its file id plus one, with zero meaning
+// the command had no file name.
+const (
+    bytecodeMagic   = "HVMB"
+    bytecodeVersion = 1
+    bytecodeHeader  = 16
+)
+
+var opcodes = []string{
+    "push", "pop", "move",
+    "add", "sub", "neg", "eq", "gt", "lt", "and", "or", "not",
+    "label", "goto", "if-goto", "ifnot-goto",
+    "function", "call", "return",
+}
+
+var segments = []string{"constant", "argument", "local", "static", "this", "that", "pointer", "temp"}
+
+type bytecodeFunction struct {
+    name      int
+    numLocals int
+    command   int
+}
+
+type bytecodeWriter struct {
+    payload   []byte
+    strings   []string
+    ids       map[string]int
+    functions []bytecodeFunction
+    commands  []byte
+    count     int
+}
+
+func (w *bytecodeWriter) uvarint(buffer *[]byte, value int) {
+    *buffer = binary.AppendUvarint(*buffer, uint64(value))
+}
+
+func (w *bytecodeWriter) stringID(s string) int {
+    if id, ok := w.ids[s]; ok {
+        return id
+    }
+    w.ids[s] = len(w.strings)
+    w.strings = append(w.strings, s)
+    return w.ids[s]
+}
+
+func (w *bytecodeWriter) segment(segment, index string) error {
+    code := indexOf(segments, segment)
+    if code == -1 {
+        return fmt.Errorf("unknown segment %s", segment)
+    }
+    value, err := strconv.Atoi(index)
+    if err != nil || value < 0 {
+        return fmt.Errorf("invalid index %s", index)
+    }
+    w.uvarint(&w.commands, code)
+    w.uvarint(&w.commands, value)
+    return nil
+}
+
+func (w *bytecodeWriter) file(args []string, position int) {
+    if len(args) > position {
+        w.uvarint(&w.commands, w.stringID(args[position])+1)
+    } else {
+        w.uvarint(&w.commands, 0)
+    }
+}
+
+func (w *bytecodeWriter) command(line string) error {
+    args := strings.Fields(line)
+    opcode := indexOf(opcodes, args[0])
+    if opcode == -1 {
+        return fmt.Errorf("unknown command")
+    }
+
+    operands := map[string]int{
+        "push": 2, "pop": 2, "move": 4,
+        "label": 1, "goto": 1, "if-goto": 1, "ifnot-goto": 1,
+        "function": 2, "call": 2,
+    }[args[0]]
+    if len(args) < operands+1 {
+        return fmt.Errorf("missing operands")
+    }
+    if hasStaticOperand(args) {
+        operands++
+    }
+    if len(args) > operands+1 {
+        return fmt.Errorf("unexpected operand %s", args[operands+1])
+    }
+
+    w.uvarint(&w.commands, opcode)
+    switch args[0] {
+    case "push", "pop":
+        if err := w.segment(args[1], args[2]); err != nil {
+            return err
+        }
+        if args[1] == "static" {
+            w.file(args, 3)
+        }
+    case "move":
+        if err := w.segment(args[1], args[2]); err != nil {
+            return err
+        }
+        if err := w.segment(args[3], args[4]); err != nil {
+            return err
+        }
+        if args[1] == "static" || args[3] == "static" {
+            w.file(args, 5)
+        }
+    case "label", "goto", "if-goto", "ifnot-goto":
+        w.uvarint(&w.commands, w.stringID(args[1]))
+    case "function":
+        w.functions = append(w.functions, bytecodeFunction{
+            name:      w.stringID(args[1]),
+            numLocals: parseInt(args[2]),
+            command:   w.count,
+        })
+        w.uvarint(&w.commands, len(w.functions)-1)
+    case "call":
+        w.uvarint(&w.commands, w.stringID(args[1]))
+        w.uvarint(&w.commands, parseInt(args[2]))
+    }
+    w.count++
+    return nil
+}
+
+func encodeBytecode(lines []string) ([]byte, error) {
+    w := &bytecodeWriter{ids: make(map[string]int)}
+    for i, line := range lines {
+        if err := w.command(line); err != nil {
+            return nil, fmt.Errorf("encodeBytecode: command %d (%s): %v", i, line, err)
+        }
+    }
+
+    w.uvarint(&w.payload, len(w.strings))
+    for _, s := range w.strings {
+        w.uvarint(&w.payload, len(s))
+        w.payload = append(w.payload, s...)
+    }
+    w.uvarint(&w.payload, len(w.functions))
+    for _, function := range w.functions {
+        w.uvarint(&w.payload, function.name)
+        w.uvarint(&w.payload, function.numLocals)
+        w.uvarint(&w.payload, function.command)
+    }
+    w.uvarint(&w.payload, w.count)
+    w.payload = append(w.payload, w.commands...)
+
+    header := make([]byte, bytecodeHeader)
+    copy(header, bytecodeMagic)
+    binary.LittleEndian.PutUint16(header[4:], bytecodeVersion)
+    binary.LittleEndian.PutUint32(header[8:], uint32(len(w.payload)))
+    binary.LittleEndian.PutUint32(header[12:], crc32.ChecksumIEEE(w.payload))
+    return append(header, w.payload...), nil
+}
+
+type bytecodeReader struct {
+    data    *bytes.Reader
+    strings []string
+}
+
+var errTruncated = errors.New("payload ends in the middle of a value")
+
+func (r *bytecodeReader) uvarint() (int, error) {
+    value, err := binary.ReadUvarint(r.data)
+    if err != nil || value > 1<<31 {
+        return 0, errTruncated
+    }
+    return int(value), nil
+}
+
+func (r *bytecodeReader) choice(table []string, what string) (string, error) {
+    value, err := r.uvarint()
+    if err != nil {
+        return "", err
+    }
+    if value >= len(table) {
+        return "", fmt.Errorf("%s %d out of range", what, value)
+    }
+    return table[value], nil
+}
+
+func (r *bytecodeReader) segment() (string, error) {
+    segment, err := r.choice(segments, "segment")
+    if err != nil {
+        return "", err
+    }
+    index, err := r.uvarint()
+    if err != nil {
+        return "", err
+    }
+    return segment + " " + strconv.Itoa(index), nil
+}
+
+func (r *bytecodeReader) file() (string, error) {
+    id, err := r.uvarint()
+    if err != nil || id == 0 {
+        return "", err
+    }
+    if id > len(r.strings) {
+        return "", fmt.Errorf("string %d out of range", id-1)
+    }
+    return " " + r.strings[id-1], nil
+}
+
+func decodeBytecode(data []byte) ([]string, error) {
+    if len(data) < bytecodeHeader || string(data[:4]) != bytecodeMagic {
+        return nil, fmt.Errorf("decodeBytecode: not a VM bytecode file")
+    }
+    if version := binary.LittleEndian.Uint16(data[4:]); version != bytecodeVersion {
+        return nil, fmt.Errorf("decodeBytecode: unsupported version %d, expected %d", version, bytecodeVersion)
+    }
+    payload := data[bytecodeHeader:]
+    if length := binary.LittleEndian.Uint32(data[8:]); int(length) != len(payload) {
+        return nil, fmt.Errorf("decodeBytecode: payload is %d bytes, header says %d", len(payload), length)
+    }
+    if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[12:]) {
+        return nil, fmt.Errorf("decodeBytecode: checksum mismatch, file is corrupt")
+    }
+
+    lines, err := readBytecodePayload(&bytecodeReader{data: bytes.NewReader(payload)})
+    if err != nil {
+        return nil, fmt.Errorf("decodeBytecode: %v", err)
+    }
+    return lines, nil
+}
+
+func readBytecodePayload(r *bytecodeReader) ([]string, error) {
+    // Every count is checked against the bytes left before anything is
+    // allocated for it: a string takes at least one byte, a function entry
+    // three and a command one.
+    count, err := r.uvarint()
+    if err != nil {
+        return nil, err
+    }
+    if count > r.data.Len() {
+        return nil, errTruncated
+    }
+    for i := 0; i < count; i++ {
+        length, err := r.uvarint()
+        if err != nil {
+            return nil, err
+        }
+        if length > r.data.Len() {
+            return nil, errTruncated
+        }
+        s := make([]byte, length)
+        r.data.Read(s)
+        r.strings = append(r.strings, string(s))
+    }
+
+    if count, err = r.uvarint(); err != nil {
+        return nil, err
+    }
+    if count > r.data.Len()/3 {
+        return nil, errTruncated
+    }
+    functions := make([]bytecodeFunction, count)
+    for i := range functions {
+        values := make([]int, 3)
+        for j := range values {
+            if values[j], err = r.uvarint(); err != nil {
+                return nil, err
+            }
+        }
+        if values[0] >= len(r.strings) {
+            return nil, fmt.Errorf("function %d has name %d out of range", i, values[0])
+        }
+        functions[i] = bytecodeFunction{name: values[0], numLocals: values[1], command: values[2]}
+    }
+
+    if count, err = r.uvarint(); err != nil {
+        return nil, err
+    }
+    if count > r.data.Len() {
+        return nil, errTruncated
+    }
+    lines := make([]string, 0, count)
+    for i := 0; i < count; i++ {
+        line, err := r.command(i, functions)
+        if err != nil {
+            return nil, fmt.Errorf("command %d: %v", i, err)
+        }
+        lines = append(lines, line)
+    }
+    if r.data.Len() != 0 {
+        return nil, fmt.Errorf("%d bytes left after the last command", r.data.Len())
+    }
+    return lines, nil
+}
+
+func (r *bytecodeReader) command(position int, functions []bytecodeFunction) (string, error) {
+    opcode, err := r.choice(opcodes, "opcode")
+    if err != nil {
+        return "", err
+    }
+
+    switch opcode {
+    case "push", "pop":
+        segment, err := r.segment()
+        if err != nil {
+            return "", err
+        }
+        file := ""
+        if strings.HasPrefix(segment, "static ") {
+            if file, err = r.file(); err != nil {
+                return "", err
+            }
+        }
+        return opcode + " " + segment + file, nil
+    case "move":
+        source, err := r.segment()
+        if err != nil {
+            return "", err
+        }
+        destination, err := r.segment()
+        if err != nil {
+            return "", err
+        }
+        file := ""
+        if strings.HasPrefix(source, "static ") || strings.HasPrefix(destination, "static ") {
+            if file, err = r.file(); err != nil {
+                return "", err
+            }
+        }
+        return opcode + " " + source + " " + destination + file, nil
+    case "label", "goto", "if-goto", "ifnot-goto":
+        name, err := r.choice(r.strings, "string")
+        if err != nil {
+            return "", err
+        }
+        return opcode + " " + name, nil
+    case "function":
+        index, err := r.uvarint()
+        if err != nil {
+            return "", err
+        }
+        if index >= len(functions) || functions[index].command != position {
+            return "", fmt.Errorf("function table entry %d does not match", index)
+        }
+        function := functions[index]
+        return fmt.Sprintf("function %s %d", r.strings[function.name], function.numLocals), nil
+    case "call":
+        name, err := r.choice(r.strings, "string")
+        if err != nil {
+            return "", err
+        }
+        numArgs, err := r.uvarint()
+        if err != nil {
+            return "", err
+        }
+        return fmt.Sprintf("call %s %d", name, numArgs), nil
+    }
+    return opcode, nil
+}
+
+func (vm *VMTranslator) readBytecode(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("readBytecode: %v", err)
+    }
+    lines, err := decodeBytecode(data)
+    if err != nil {
+        return fmt.Errorf("%s: %v", path, err)
+    }
+    vm.parsedContent = append(vm.parsedContent, lines...)
+    return nil
+}
+
+func indexOf(list []string, s string) int {
+    for i, item := range list {
+        if item == s {
+            return i
+        }
+    }
+    return -1
+}
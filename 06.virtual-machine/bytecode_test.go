@@ -0,0 +1,68 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+// A program encoded to bytecode, decoded, written out as VM text and read
+// back must come out as the same commands, statics included, and run the
+// same way.
+func TestBytecodeRoundTrip(t *testing.T) {
+    tests := []struct {
+        name      string
+        directory func(t *testing.T) string
+        entry     string
+    }{
+        {"basic", func(t *testing.T) string { return "testdata/basic" }, "Sys.init"},
+        {"objects", func(t *testing.T) string { return jackProgram(t, "objects") }, "Sys.init"},
+        {
+            // Commands outside any function, as in the project 7 tests.
+            "no functions", func(t *testing.T) string {
+                return writeProgram(t, map[string]string{"StaticTest.vm": `push constant 111
+pop static 8
+push static 8
+push constant 1
+add
+pop static 3
+`})
+            }, "",
+        },
+    }
+
+    for _, test := range tests {
+        for _, optimized := range []bool{false, true} {
+            name := test.name
+            if optimized {
+                name += " optimized"
+            }
+            t.Run(name, func(t *testing.T) {
+                directory := test.directory(t)
+                lines := prepare(t, directory, func(vm *VMTranslator) {
+                    vm.optimize = optimized
+                })
+
+                code, err := encodeBytecode(lines)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                decoded, err := decodeBytecode(code)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                files, err := vmFiles(decoded, "Sys")
+                if err != nil {
+                    t.Fatal(err)
+                }
+                reread := prepare(t, writeProgram(t, files), nil)
+                if got, want := strings.Join(reread, "\n"), strings.Join(lines, "\n"); got != want {
+                    t.Fatalf("read back:\n%s\nwant:\n%s", got, want)
+                }
+
+                if test.entry != "" {
+                    compareRuns(t, interpret(t, lines, test.entry), interpret(t, reread, test.entry))
+                }
+            })
+        }
+    }
+}
@@ -0,0 +1,224 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The C runtime keeps RAM as unsigned 16-bit words so every wrap-around is
+// defined behaviour, and converts to signed only where the sign matters.
+const cRuntime = `#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+static uint16_t RAM[32768];
+
+#define M(address) RAM[(address) & 0x7fff]
+#define PUSH(value) (M(RAM[0]) = (uint16_t)(value), RAM[0]++)
+#define POP() (RAM[0]--, M(RAM[0]))
+#define TRUE 0xffff
+
+static int s16(uint16_t value) {
+    return value >= 0x8000 ? (int)value - 0x10000 : (int)value;
+}
+
+static int printRAM(const char *ranges) {
+    const char *part = ranges;
+    while (*part != '\0') {
+        char *end;
+        long from = strtol(part, &end, 10);
+        long to = from;
+        if (end != part && *end == '-') {
+            part = end + 1;
+            to = strtol(part, &end, 10);
+        }
+        if (end == part || (*end != ',' && *end != '\0') || from < 0 || to < 0) {
+            fprintf(stderr, "invalid RAM range %%s\n", ranges);
+            return 1;
+        }
+        for (long address = from; address <= to && address < 32768; address++) {
+            printf("RAM[%%ld] = %%d\n", address, s16(RAM[address]));
+        }
+        part = *end == ',' ? end + 1 : end;
+    }
+    return 0;
+}
+
+static void printScreen(void) {
+    printf("P1\n512 256\n");
+    for (int y = 0; y < 256; y++) {
+        for (int x = 0; x < 512; x++) {
+            putchar(RAM[%[1]d + y * 32 + x / 16] >> (x %% 16) & 1 ? '1' : '0');
+        }
+        putchar('\n');
+    }
+}
+
+static int report(int argc, char **argv) {
+    for (int i = 1; i < argc; i++) {
+        if (strcmp(argv[i], "-ram") == 0 && i + 1 < argc) {
+            if (printRAM(argv[++i]) != 0) {
+                return 1;
+            }
+        } else if (strcmp(argv[i], "-screen") == 0) {
+            printScreen();
+        } else {
+            fprintf(stderr, "usage: %%s [-ram 16-20,2048] [-screen]\n", argv[0]);
+            return 1;
+        }
+    }
+    return 0;
+}
+
+int main(int argc, char **argv) {
+    uint16_t frame, returnAddress, x, y;
+
+    RAM[0] = %[2]d;
+    PUSH(0);
+    PUSH(RAM[1]);
+    PUSH(RAM[2]);
+    PUSH(RAM[3]);
+    PUSH(RAM[4]);
+    RAM[2] = RAM[0] - 5;
+    RAM[1] = RAM[0];
+    goto %[3]s;
+`
+
+// generateC emits the whole program as one C function. VM labels and
+// functions become C labels and returns go through a switch on the return
+// address pushed by the call, so the output needs nothing beyond C99.
+func (vm *VMTranslator) generateC(lines []string) (string, error) {
+    layout, err := newProgramLayout(lines, nil)
+    if err != nil {
+        return "", err
+    }
+    if _, ok := layout.functions[vm.entry]; !ok {
+        return "", fmt.Errorf("generateC: entry point %s is not defined", vm.entry)
+    }
+
+    var code strings.Builder
+    layout.targets[vm.entry] = true
+    fmt.Fprintf(&code, cRuntime, screenBase, stackBase, cFunctionLabel(layout, vm.entry))
+
+    for i, line := range lines {
+        fmt.Fprintf(&code, "\n    /* %s */\n", strings.ReplaceAll(line, "*/", "* /"))
+        for _, statement := range cStatements(layout, lines, i) {
+            fmt.Fprintf(&code, "    %s\n", statement)
+        }
+    }
+
+    code.WriteString("\n    goto halt;\n\ndispatch:\n    switch (returnAddress) {\n")
+    for i := range lines {
+        if id, ok := layout.returns[i]; ok {
+            fmt.Fprintf(&code, "    case %d: goto R%d;\n", id, id)
+        }
+    }
+    code.WriteString("    }\n\nhalt:\n    return report(argc, argv);\n}\n")
+    return code.String(), nil
+}
+
+func cFunctionLabel(layout *programLayout, name string) string {
+    return fmt.Sprintf("F%d", layout.functions[name])
+}
+
+func cLocation(layout *programLayout, segment, index, fileName string) string {
+    switch segment {
+    case "local":
+        return fmt.Sprintf("M(RAM[1] + %s)", index)
+    case "argument":
+        return fmt.Sprintf("M(RAM[2] + %s)", index)
+    case "this":
+        return fmt.Sprintf("M(RAM[3] + %s)", index)
+    case "that":
+        return fmt.Sprintf("M(RAM[4] + %s)", index)
+    case "temp":
+        return fmt.Sprintf("RAM[%d]", 5+parseInt(index))
+    case "pointer":
+        return fmt.Sprintf("RAM[%d]", 3+parseInt(index))
+    case "static":
+        return fmt.Sprintf("RAM[%d]", layout.statics[fileName+"."+index])
+    }
+    return ""
+}
+
+func cValue(layout *programLayout, segment, index, fileName string) string {
+    if segment == "constant" {
+        return index
+    }
+    return cLocation(layout, segment, index, fileName)
+}
+
+func cStatements(layout *programLayout, lines []string, i int) []string {
+    args := strings.Fields(lines[i])
+
+    switch args[0] {
+    case "push":
+        return []string{fmt.Sprintf("PUSH(%s);", cValue(layout, args[1], args[2], fileArg(args, 3)))}
+    case "pop":
+        return []string{fmt.Sprintf("y = POP(); %s = y;", cLocation(layout, args[1], args[2], fileArg(args, 3)))}
+    case "move":
+        return []string{fmt.Sprintf("%s = %s;",
+            cLocation(layout, args[3], args[4], fileArg(args, 5)), cValue(layout, args[1], args[2], fileArg(args, 5)))}
+    case "neg":
+        return []string{"x = POP(); PUSH(-x);"}
+    case "not":
+        return []string{"x = POP(); PUSH(~x);"}
+    case "add", "sub", "and", "or":
+        operator := map[string]string{"add": "+", "sub": "-", "and": "&", "or": "|"}[args[0]]
+        return []string{fmt.Sprintf("y = POP(); x = POP(); PUSH(x %s y);", operator)}
+    case "eq", "gt", "lt":
+        operator := map[string]string{"eq": "==", "gt": ">", "lt": "<"}[args[0]]
+        return []string{fmt.Sprintf("y = POP(); x = POP(); PUSH(s16((uint16_t)(x - y)) %s 0 ? TRUE : 0);", operator)}
+    case "label":
+        if !layout.targets[args[1]] {
+            return nil
+        }
+        return []string{fmt.Sprintf("L%d:;", layout.labels[args[1]])}
+    case "goto":
+        if isHaltLoop(lines, i) {
+            return []string{"goto halt;"}
+        }
+        return []string{fmt.Sprintf("goto L%d;", layout.labels[args[1]])}
+    case "if-goto":
+        return []string{fmt.Sprintf("if (POP() != 0) goto L%d;", layout.labels[args[1]])}
+    case "ifnot-goto":
+        return []string{fmt.Sprintf("if (POP() != TRUE) goto L%d;", layout.labels[args[1]])}
+    case "function":
+        var statements []string
+        if layout.targets[args[1]] {
+            statements = append(statements, cFunctionLabel(layout, args[1])+":;")
+        }
+        if args[1] == haltFunction {
+            statements = append(statements, "goto halt;")
+        }
+        for n := 0; n < parseInt(args[2]); n++ {
+            statements = append(statements, "PUSH(0);")
+        }
+        return statements
+    case "call":
+        id := layout.returns[i]
+        return []string{
+            fmt.Sprintf("PUSH(%d);", id),
+            "PUSH(RAM[1]); PUSH(RAM[2]); PUSH(RAM[3]); PUSH(RAM[4]);",
+            fmt.Sprintf("RAM[2] = RAM[0] - %d;", 5+parseInt(args[2])),
+            "RAM[1] = RAM[0];",
+            fmt.Sprintf("goto %s;", cFunctionLabel(layout, args[1])),
+            fmt.Sprintf("R%d:;", id),
+        }
+    case "return":
+        return []string{
+            "frame = RAM[1];",
+            "returnAddress = M(frame - 5);",
+            "M(RAM[2]) = POP();",
+            "RAM[0] = RAM[2] + 1;",
+            "RAM[4] = M(frame - 1);",
+            "RAM[3] = M(frame - 2);",
+            "RAM[2] = M(frame - 3);",
+            "RAM[1] = M(frame - 4);",
+            "if (returnAddress == 0) goto halt;",
+            "goto dispatch;",
+        }
+    }
+    return nil
+}
@@ -0,0 +1,118 @@
+package vmtranslator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func buildCallGraph(functions []vmFunction) map[string][]string {
+    graph := make(map[string][]string)
+
+    for _, function := range functions {
+        for _, line := range function.lines {
+            if strings.HasPrefix(line, "call") {
+                graph[function.name] = append(graph[function.name], strings.Fields(line)[1])
+            }
+        }
+    }
+    return graph
+}
+
+// checkCalls reports each function that calls a function no file defines,
+// and an entry point that is not defined.
+func checkCalls(lines []string, entry string) error {
+    defined := map[string]bool{}
+    for _, line := range lines {
+        if args := strings.Fields(line); args[0] == "function" {
+            defined[args[1]] = true
+        }
+    }
+
+    var errs []error
+    if !defined[entry] {
+        errs = append(errs, fmt.Errorf("checkCalls: entry point %s is not defined", entry))
+    }
+    reported := map[string]bool{}
+    function := ""
+    for _, line := range lines {
+        args := strings.Fields(line)
+        switch args[0] {
+        case "function":
+            function = args[1]
+        case "call":
+            if !defined[args[1]] && !reported[function+" "+args[1]] {
+                reported[function+" "+args[1]] = true
+                errs = append(errs, fmt.Errorf("checkCalls: %s calls undefined function %s", function, args[1]))
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+func reachableFunctions(graph map[string][]string, roots []string) map[string]bool {
+    reachable := make(map[string]bool)
+    queue := append([]string{}, roots...)
+
+    for len(queue) > 0 {
+        name := queue[0]
+        queue = queue[1:]
+        if reachable[name] {
+            continue
+        }
+        reachable[name] = true
+        queue = append(queue, graph[name]...)
+    }
+    return reachable
+}
+
+func (vm *VMTranslator) removeDeadFunctions(lines []string) ([]string, error) {
+    functions := splitFunctions(lines)
+
+    defined := make(map[string]bool)
+    for _, function := range functions {
+        defined[function.name] = true
+    }
+    if !defined[vm.entry] {
+        return nil, fmt.Errorf("removeDeadFunctions: entry point %s is not defined", vm.entry)
+    }
+
+    roots := []string{"", vm.entry}
+    for _, name := range vm.keep {
+        if !defined[name] {
+            return nil, fmt.Errorf("removeDeadFunctions: kept function %s is not defined", name)
+        }
+        roots = append(roots, name)
+    }
+    reachable := reachableFunctions(buildCallGraph(functions), roots)
+
+    var result []string
+    var removed []vmFunction
+    for _, function := range functions {
+        if reachable[function.name] {
+            result = append(result, function.lines...)
+        } else {
+            removed = append(removed, function)
+        }
+    }
+
+    vm.reportDeadFunctions(removed)
+    return result, nil
+}
+
+func (vm *VMTranslator) reportDeadFunctions(removed []vmFunction) {
+    sort.Slice(removed, func(i, j int) bool {
+        return removed[i].name < removed[j].name
+    })
+
+    saved := 0
+    fmt.Fprintf(os.Stderr, "Removed %d functions unreachable from %s:\n", len(removed), vm.entry)
+    for _, function := range removed {
+        instructions := countInstructions(function.lines)
+        saved += instructions
+        fmt.Fprintf(os.Stderr, "  %-32s %6d instructions\n", function.name, instructions)
+    }
+    fmt.Fprintf(os.Stderr, "  %-32s %6d instructions\n", "ROM saved", saved)
+}
@@ -0,0 +1,96 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+// Main.unused and Main.helper, which only Main.unused calls, are never
+// reached from Main.test; Main.kept is not either, but can be kept by name.
+const deadFunctionsProgram = `function Main.test 0
+push constant 5
+call Main.used 1
+return
+function Main.used 0
+push argument 0
+push constant 2
+add
+pop static 0
+push static 0
+return
+function Main.unused 0
+call Main.helper 0
+return
+function Main.helper 0
+push constant 1
+return
+function Main.kept 0
+push constant 9
+return
+`
+
+func TestRemoveDeadFunctions(t *testing.T) {
+    directory := writeProgram(t, map[string]string{"Main.vm": deadFunctionsProgram})
+    want := interpret(t, prepare(t, directory, nil), "Main.test")
+
+    tests := []struct {
+        keep    []string
+        present []string
+        absent  []string
+    }{
+        {
+            present: []string{"function Main.test", "function Main.used"},
+            absent:  []string{"function Main.unused", "function Main.helper", "function Main.kept"},
+        },
+        {
+            keep:    []string{"Main.kept"},
+            present: []string{"function Main.test", "function Main.used", "function Main.kept"},
+            absent:  []string{"function Main.unused", "function Main.helper"},
+        },
+    }
+
+    for _, test := range tests {
+        configure := func(vm *VMTranslator) {
+            vm.entry = "Main.test"
+            vm.removeDead = true
+            vm.keep = test.keep
+        }
+        lines := prepare(t, directory, configure)
+        for _, prefix := range test.present {
+            if !hasCommand(lines, prefix) {
+                t.Errorf("-keep %v: %q was removed:\n%s", test.keep, prefix, strings.Join(lines, "\n"))
+            }
+        }
+        for _, prefix := range test.absent {
+            if hasCommand(lines, prefix) {
+                t.Errorf("-keep %v: %q was not removed:\n%s", test.keep, prefix, strings.Join(lines, "\n"))
+            }
+        }
+
+        compareRuns(t, want, interpret(t, lines, "Main.test"))
+        emulated, _ := emulate(t, directory, "Main.test", configure)
+        compareRuns(t, want, emulated)
+    }
+}
+
+func TestRemoveDeadFunctionsUndefinedNames(t *testing.T) {
+    directory := writeProgram(t, map[string]string{"Main.vm": deadFunctionsProgram})
+    tests := []struct {
+        entry string
+        keep  []string
+        err   string
+    }{
+        {entry: "Main.missing", err: "entry point Main.missing is not defined"},
+        {entry: "Main.test", keep: []string{"Main.kept", "Main.gone"}, err: "kept function Main.gone is not defined"},
+    }
+
+    for _, test := range tests {
+        vm := NewVMTranslator(directory, true)
+        vm.entry = test.entry
+        vm.removeDead = true
+        vm.keep = test.keep
+        if err := vm.prepare(); err == nil || !strings.Contains(err.Error(), test.err) {
+            t.Errorf("entry %s, -keep %v: got error %v, want %q", test.entry, test.keep, err, test.err)
+        }
+    }
+}
@@ -0,0 +1,7 @@
+package main
+
+import vmtranslator "nand2tetris/06.virtual-machine"
+
+func main() {
+	vmtranslator.Main()
+}
@@ -0,0 +1,444 @@
+package vmtranslator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// The executable is one read-write-execute segment loaded at elfBase: the
+// ELF and program headers, the code, a little initialised data and then RAM
+// and the screen text buffer as zero-filled memory.
+const (
+    elfBase       = 0x400000
+    elfHeaderSize = 64 + 56
+    ramWords      = 32768
+    screenText    = 256 * (512 + 1)
+)
+
+// x86-64 register numbers used by the generator. RBX always holds the
+// address of RAM, so RAM[i] is the word at [rbx + 2*i].
+const (
+    rax = 0
+    rcx = 1
+    rdx = 2
+    rsi = 6
+    rdi = 7
+)
+
+// Calls to these functions are handled by the runtime when the program does
+// not define them, so programs without an OS can still print to stdout.
+var elfBuiltins = map[string]bool{
+    "Output.printChar": true,
+    "Output.println":   true,
+}
+
+type x64Fixup struct {
+    position int
+    label    string
+}
+
+type x64Assembler struct {
+    code      []byte
+    labels    map[string]int
+    jumps     []x64Fixup
+    addresses []x64Fixup
+}
+
+func (a *x64Assembler) emit(code ...byte) {
+    a.code = append(a.code, code...)
+}
+
+func (a *x64Assembler) imm32(value int) {
+    a.code = binary.LittleEndian.AppendUint32(a.code, uint32(value))
+}
+
+func (a *x64Assembler) label(name string) {
+    a.labels[name] = len(a.code)
+}
+
+// rel32 leaves room for a jump offset to a code label.
+func (a *x64Assembler) rel32(label string) {
+    a.jumps = append(a.jumps, x64Fixup{position: len(a.code), label: label})
+    a.imm32(0)
+}
+
+// abs32 leaves room for the absolute address of a data symbol, which is only
+// known once the size of the code is.
+func (a *x64Assembler) abs32(symbol string) {
+    a.addresses = append(a.addresses, x64Fixup{position: len(a.code), label: symbol})
+    a.imm32(0)
+}
+
+func (a *x64Assembler) jump(opcode []byte, label string) {
+    a.emit(opcode...)
+    a.rel32(label)
+}
+
+func (a *x64Assembler) movImm(register, value int) {
+    a.emit(byte(0xB8 + register))
+    a.imm32(value)
+}
+
+func (a *x64Assembler) movAddress(register int, symbol string) {
+    a.emit(byte(0xB8 + register))
+    a.abs32(symbol)
+}
+
+func (a *x64Assembler) loadRAM(register, address int) {
+    a.emit(0x0F, 0xB7, byte(0x80|register<<3|3))
+    a.imm32(2 * address)
+}
+
+func (a *x64Assembler) storeRAM(register, address int) {
+    a.emit(0x66, 0x89, byte(0x80|register<<3|3))
+    a.imm32(2 * address)
+}
+
+func (a *x64Assembler) loadIndexed(register, index int) {
+    a.emit(0x0F, 0xB7, byte(register<<3|4), byte(0x40|index<<3|3))
+}
+
+func (a *x64Assembler) storeIndexed(register, index int) {
+    a.emit(0x66, 0x89, byte(register<<3|4), byte(0x40|index<<3|3))
+}
+
+func (a *x64Assembler) mask(register int) {
+    a.emit(0x81, byte(0xE0|register))
+    a.imm32(0x7fff)
+}
+
+func (a *x64Assembler) addImm(register, value int) {
+    a.emit(0x81, byte(0xC0|register))
+    a.imm32(value)
+}
+
+func (a *x64Assembler) subImm(register, value int) {
+    a.emit(0x81, byte(0xE8|register))
+    a.imm32(value)
+}
+
+func (a *x64Assembler) syscall() {
+    a.emit(0x0F, 0x05)
+}
+
+func (a *x64Assembler) write(symbol string, length int) {
+    a.movImm(rax, 1)
+    a.movImm(rdi, 1)
+    a.movAddress(rsi, symbol)
+    a.movImm(rdx, length)
+    a.syscall()
+}
+
+// push stores AX on top of the stack.
+func (a *x64Assembler) push() {
+    a.loadRAM(rcx, 0)
+    a.mask(rcx)
+    a.storeIndexed(rax, rcx)
+    a.emit(0x66, 0x83, 0x83, 0, 0, 0, 0, 1)
+}
+
+// pop takes the top of the stack into EAX.
+func (a *x64Assembler) pop() {
+    a.emit(0x66, 0x83, 0xAB, 0, 0, 0, 0, 1)
+    a.loadRAM(rcx, 0)
+    a.mask(rcx)
+    a.loadIndexed(rax, rcx)
+}
+
+type elfGenerator struct {
+    asm    *x64Assembler
+    layout *programLayout
+}
+
+// segmentAddress puts the RAM address of an indirect segment entry into
+// register and reports false, or returns the fixed address of a direct one.
+func (g *elfGenerator) segmentAddress(register int, segment, index, fileName string) (int, bool) {
+    switch segment {
+    case "temp":
+        return 5 + parseInt(index), true
+    case "pointer":
+        return 3 + parseInt(index), true
+    case "static":
+        return g.layout.statics[fileName+"."+index], true
+    }
+
+    base := map[string]int{"local": 1, "argument": 2, "this": 3, "that": 4}[segment]
+    g.asm.loadRAM(register, base)
+    if offset := parseInt(index); offset != 0 {
+        g.asm.addImm(register, offset)
+    }
+    g.asm.mask(register)
+    return 0, false
+}
+
+func (g *elfGenerator) load(segment, index, fileName string) {
+    if segment == "constant" {
+        g.asm.movImm(rax, parseInt(index))
+        return
+    }
+    if address, direct := g.segmentAddress(rcx, segment, index, fileName); direct {
+        g.asm.loadRAM(rax, address)
+    } else {
+        g.asm.loadIndexed(rax, rcx)
+    }
+}
+
+func (g *elfGenerator) store(segment, index, fileName string) {
+    if address, direct := g.segmentAddress(rdx, segment, index, fileName); direct {
+        g.asm.storeRAM(rax, address)
+    } else {
+        g.asm.storeIndexed(rax, rdx)
+    }
+}
+
+// call builds the VM frame in RAM exactly like the other backends, with a
+// zero where the return address would go, and then uses a native call.
+func (g *elfGenerator) call(name string, numArgs int) {
+    a := g.asm
+    a.movImm(rax, 0)
+    a.push()
+    for pointer := 1; pointer <= 4; pointer++ {
+        a.loadRAM(rax, pointer)
+        a.push()
+    }
+    a.loadRAM(rax, 0)
+    a.subImm(rax, 5+numArgs)
+    a.storeRAM(rax, 2)
+    a.loadRAM(rax, 0)
+    a.storeRAM(rax, 1)
+    a.jump([]byte{0xE8}, "F:"+name)
+}
+
+func (g *elfGenerator) builtin(name string) {
+    a := g.asm
+    switch name {
+    case "Output.printChar":
+        a.pop()
+        a.emit(0x88, 0x04, 0x25)
+        a.abs32("char")
+    case "Output.println":
+        a.emit(0xC6, 0x04, 0x25)
+        a.abs32("char")
+        a.emit('\n')
+    }
+    a.write("char", 1)
+    a.movImm(rax, 0)
+    a.push()
+}
+
+func (g *elfGenerator) ret() {
+    a := g.asm
+    a.emit(0x0F, 0xB7, 0xB3)
+    a.imm32(2)
+    a.pop()
+    a.loadRAM(rdx, 2)
+    a.mask(rdx)
+    a.storeIndexed(rax, rdx)
+    a.loadRAM(rax, 2)
+    a.addImm(rax, 1)
+    a.storeRAM(rax, 0)
+    for offset, pointer := 1, 4; pointer >= 1; offset, pointer = offset+1, pointer-1 {
+        a.emit(0x89, 0xF1)
+        a.subImm(rcx, offset)
+        a.mask(rcx)
+        a.loadIndexed(rax, rcx)
+        a.storeRAM(rax, pointer)
+    }
+    a.emit(0xC3)
+}
+
+func (g *elfGenerator) command(lines []string, i int) {
+    a := g.asm
+    args := strings.Fields(lines[i])
+
+    switch args[0] {
+    case "push":
+        g.load(args[1], args[2], fileArg(args, 3))
+        a.push()
+    case "pop":
+        a.pop()
+        g.store(args[1], args[2], fileArg(args, 3))
+    case "move":
+        g.load(args[1], args[2], fileArg(args, 5))
+        g.store(args[3], args[4], fileArg(args, 5))
+    case "neg", "not":
+        a.pop()
+        if args[0] == "neg" {
+            a.emit(0xF7, 0xD8)
+        } else {
+            a.emit(0xF7, 0xD0)
+        }
+        a.push()
+    case "add", "sub", "and", "or", "eq", "gt", "lt":
+        a.pop()
+        a.emit(0x89, 0xC2)
+        a.pop()
+        switch args[0] {
+        case "add":
+            a.emit(0x01, 0xD0)
+        case "sub":
+            a.emit(0x29, 0xD0)
+        case "and":
+            a.emit(0x21, 0xD0)
+        case "or":
+            a.emit(0x09, 0xD0)
+        default:
+            // sign of the 16-bit difference, like the assembly backend
+            setcc := map[string]byte{"eq": 0x94, "gt": 0x9F, "lt": 0x9C}[args[0]]
+            a.emit(0x29, 0xD0, 0x66, 0x85, 0xC0, 0x0F, setcc, 0xC0, 0x0F, 0xB6, 0xC0, 0xF7, 0xD8)
+        }
+        a.push()
+    case "label":
+        a.label("L:" + args[1])
+    case "goto":
+        if isHaltLoop(lines, i) {
+            a.jump([]byte{0xE9}, "rt.exit")
+        } else {
+            a.jump([]byte{0xE9}, "L:"+args[1])
+        }
+    case "if-goto":
+        a.pop()
+        a.emit(0x66, 0x85, 0xC0)
+        a.jump([]byte{0x0F, 0x85}, "L:"+args[1])
+    case "ifnot-goto":
+        a.pop()
+        a.emit(0x66, 0x3D, 0xFF, 0xFF)
+        a.jump([]byte{0x0F, 0x85}, "L:"+args[1])
+    case "function":
+        a.label("F:" + args[1])
+        if args[1] == haltFunction {
+            a.jump([]byte{0xE9}, "rt.exit")
+        }
+        if numLocals := parseInt(args[2]); numLocals > 0 {
+            a.movImm(rax, 0)
+            for n := 0; n < numLocals; n++ {
+                a.push()
+            }
+        }
+    case "call":
+        if _, defined := g.layout.functions[args[1]]; !defined {
+            g.builtin(args[1])
+        } else {
+            g.call(args[1], parseInt(args[2]))
+        }
+    case "return":
+        g.ret()
+    }
+}
+
+// exit runs when the program halts. With "ram" as the first argument it
+// writes the 64 KiB RAM image to stdout, otherwise the screen as a PBM image.
+func (g *elfGenerator) exit() {
+    a := g.asm
+    a.label("rt.exit")
+    a.emit(0x48, 0x8B, 0x45, 0x00)
+    a.emit(0x48, 0x83, 0xF8, 0x01)
+    a.jump([]byte{0x0F, 0x8E}, "rt.screen")
+    a.emit(0x48, 0x8B, 0x75, 0x10)
+    a.emit(0x80, 0x3E, 'r')
+    a.jump([]byte{0x0F, 0x85}, "rt.screen")
+    a.write("ram", 2*ramWords)
+    a.jump([]byte{0xE9}, "rt.done")
+
+    a.label("rt.screen")
+    a.movAddress(rdi, "screen")
+    a.emit(0x31, 0xC9)
+    a.label("rt.word")
+    a.emit(0x0F, 0xB7, 0x84, 0x4B)
+    a.imm32(2 * screenBase)
+    a.movImm(rdx, 16)
+    a.label("rt.bit")
+    a.emit(0xC6, 0x07, '0')
+    a.emit(0xA8, 0x01, 0x74, 0x02, 0xFE, 0x07)
+    a.emit(0x48, 0xFF, 0xC7)
+    a.emit(0xD1, 0xE8)
+    a.emit(0xFF, 0xCA)
+    a.jump([]byte{0x0F, 0x85}, "rt.bit")
+    a.emit(0xFF, 0xC1)
+    a.emit(0xF6, 0xC1, 0x1F)
+    a.jump([]byte{0x0F, 0x85}, "rt.next")
+    a.emit(0xC6, 0x07, '\n')
+    a.emit(0x48, 0xFF, 0xC7)
+    a.label("rt.next")
+    a.emit(0x81, 0xF9)
+    a.imm32(keyboardBase - screenBase)
+    a.jump([]byte{0x0F, 0x82}, "rt.word")
+    a.write("header", len(pbmHeader))
+    a.write("screen", screenText)
+
+    a.label("rt.done")
+    a.movImm(rax, 60)
+    a.emit(0x31, 0xFF)
+    a.syscall()
+}
+
+const pbmHeader = "P1\n512 256\n"
+
+// generateELF translates the program to x86-64 machine code and wraps it in
+// a static ELF executable that needs nothing but the Linux kernel.
+func (vm *VMTranslator) generateELF(lines []string) ([]byte, error) {
+    layout, err := newProgramLayout(lines, elfBuiltins)
+    if err != nil {
+        return nil, err
+    }
+    if _, ok := layout.functions[vm.entry]; !ok {
+        return nil, fmt.Errorf("generateELF: entry point %s is not defined", vm.entry)
+    }
+
+    a := &x64Assembler{labels: make(map[string]int)}
+    g := &elfGenerator{asm: a, layout: layout}
+
+    // mov rbp, rsp keeps argc and argv reachable after the program halts
+    // deep inside nested calls; mov ebx, RAM and SP = 256 start the VM.
+    a.emit(0x48, 0x89, 0xE5)
+    a.movAddress(3, "ram")
+    a.emit(0x66, 0xC7, 0x83, 0, 0, 0, 0)
+    a.emit(byte(stackBase&0xff), byte(stackBase>>8))
+    g.call(vm.entry, 0)
+    a.jump([]byte{0xE9}, "rt.exit")
+
+    for i := range lines {
+        g.command(lines, i)
+    }
+    g.exit()
+
+    for _, jump := range a.jumps {
+        target, ok := a.labels[jump.label]
+        if !ok {
+            return nil, fmt.Errorf("generateELF: label %s is not defined", strings.TrimPrefix(jump.label, "L:"))
+        }
+        binary.LittleEndian.PutUint32(a.code[jump.position:], uint32(target-(jump.position+4)))
+    }
+
+    codeStart := elfBase + elfHeaderSize
+    data := map[string]int{"header": codeStart + len(a.code)}
+    data["char"] = data["header"] + len(pbmHeader)
+    fileSize := elfHeaderSize + len(a.code) + len(pbmHeader) + 1
+    data["ram"] = (elfBase + fileSize + 15) &^ 15
+    data["screen"] = data["ram"] + 2*ramWords
+    memorySize := data["screen"] + screenText - elfBase
+
+    for _, fixup := range a.addresses {
+        binary.LittleEndian.PutUint32(a.code[fixup.position:], uint32(data[fixup.label]))
+    }
+
+    var file bytes.Buffer
+    file.Write([]byte{0x7F, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+    binary.Write(&file, binary.LittleEndian, struct {
+        Type, Machine                                        uint16
+        Version                                              uint32
+        Entry, PhOff, ShOff                                  uint64
+        Flags                                                uint32
+        EhSize, PhEntSize, PhNum, ShEntSize, ShNum, ShStrNdx uint16
+    }{2, 0x3E, 1, uint64(codeStart), 64, 0, 0, 64, 56, 1, 0, 0, 0})
+    binary.Write(&file, binary.LittleEndian, struct {
+        Type, Flags                                    uint32
+        Offset, VAddr, PAddr, FileSize, MemSize, Align uint64
+    }{1, 7, 0, elfBase, elfBase, uint64(fileSize), uint64(memorySize), 0x1000})
+    file.Write(a.code)
+    file.WriteString(pbmHeader)
+    file.WriteByte(0)
+    return file.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package vmtranslator
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// runELF builds the program in directory as an executable calling entry,
+// runs it with the "ram" argument and reads back the RAM image it writes
+// when it halts.
+func runELF(t *testing.T, directory, entry string, returns bool, configure func(vm *VMTranslator)) runResult {
+    t.Helper()
+    lines := prepare(t, directory, configure)
+    vm := NewVMTranslator(directory, true)
+    vm.entry = entry
+    code, err := vm.generateELF(lines)
+    if err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(t.TempDir(), "Sys")
+    if err := os.WriteFile(path, code, 0755); err != nil {
+        t.Fatal(err)
+    }
+    output, err := exec.Command(path, "ram").Output()
+    if err != nil {
+        t.Fatalf("running the executable: %v", err)
+    }
+    if len(output) != 2*ramWords {
+        t.Fatalf("the executable wrote %d bytes of RAM, want %d", len(output), 2*ramWords)
+    }
+
+    var ram [ramWords]int16
+    for i := range ram {
+        ram[i] = int16(binary.LittleEndian.Uint16(output[2*i:]))
+    }
+    layout, err := newProgramLayout(lines, elfBuiltins)
+    if err != nil {
+        t.Fatal(err)
+    }
+    result := runResult{
+        hasReturn: returns,
+        statics:   make(map[string]int16),
+        memory:    append([]int16{}, ram[heapBase:keyboardBase]...),
+    }
+    if returns {
+        result.returned = ram[ram[0]-1]
+    }
+    for name, address := range layout.statics {
+        result.statics[name] = ram[address]
+    }
+    return result
+}
+
+func TestELFMatchesInterpreterAndEmulator(t *testing.T) {
+    if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+        t.Skipf("the executable needs linux/amd64, not %s/%s", runtime.GOOS, runtime.GOARCH)
+    }
+
+    for name, directory := range map[string]func(t *testing.T) string{
+        "basic":   func(t *testing.T) string { return "testdata/basic" },
+        "objects": func(t *testing.T) string { return jackProgram(t, "objects") },
+    } {
+        t.Run(name, func(t *testing.T) {
+            directory := directory(t)
+            want := interpret(t, prepare(t, directory, nil), "Sys.init")
+            emulated, _ := emulate(t, directory, "Sys.init", nil)
+            compareRuns(t, want, emulated)
+            compareRuns(t, want, runELF(t, directory, "Sys.init", false, nil))
+            compareRuns(t, want, runELF(t, directory, "Sys.init", false, optimize))
+        })
+    }
+
+    t.Run("return value", func(t *testing.T) {
+        directory := writeProgram(t, map[string]string{"Main.vm": `function Main.test 1
+push constant 3000
+pop pointer 1
+push constant 12
+push constant 34
+call Main.multiply 2
+pop local 0
+push local 0
+pop that 0
+push constant 5
+neg
+push constant 3
+lt
+pop that 1
+push local 0
+push constant 400
+gt
+pop static 0
+push local 0
+push static 0
+sub
+return
+function Main.multiply 1
+label MULTIPLY_LOOP
+push argument 1
+if-goto MULTIPLY_STEP
+push local 0
+return
+label MULTIPLY_STEP
+push local 0
+push argument 0
+add
+pop local 0
+push argument 1
+push constant 1
+sub
+pop argument 1
+goto MULTIPLY_LOOP
+`})
+        want := interpret(t, prepare(t, directory, nil), "Main.test")
+        emulated, _ := emulate(t, directory, "Main.test", nil)
+        compareRuns(t, want, emulated)
+        compareRuns(t, want, runELF(t, directory, "Main.test", true, nil))
+    })
+}
@@ -0,0 +1,439 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"go/format"
+	"regexp"
+	"strings"
+)
+
+// goRuntime is copied into every generated program. VM functions become Go
+// functions that keep the usual frames in RAM, so memory looks exactly as it
+// does under the other backends, while the Go call stack does the jumping.
+const goRuntime = `package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	screenBase   = 16384
+	keyboardBase = 24576
+)
+
+var RAM [32768]int16
+
+// functions maps VM function names to the implementation used at run time,
+// so native hooks can replace VM code by name.
+var functions = map[string]*func(){}
+
+type halted struct{}
+
+func at(address int16) *int16 {
+	return &RAM[uint16(address)&0x7fff]
+}
+
+func push(value int16) {
+	*at(RAM[0]) = value
+	RAM[0]++
+}
+
+func pop() int16 {
+	RAM[0]--
+	return *at(RAM[0])
+}
+
+func boolean(b bool) int16 {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+func add() { y := pop(); push(pop() + y) }
+func sub() { y := pop(); push(pop() - y) }
+func and() { y := pop(); push(pop() & y) }
+func or()  { y := pop(); push(pop() | y) }
+func neg() { push(-pop()) }
+func not() { push(^pop()) }
+
+// The comparisons test the sign of x-y like the assembly backend does.
+func eq() { y := pop(); push(boolean(pop()-y == 0)) }
+func gt() { y := pop(); push(boolean(pop()-y > 0)) }
+func lt() { y := pop(); push(boolean(pop()-y < 0)) }
+
+func locals(n int) {
+	for i := 0; i < n; i++ {
+		push(0)
+	}
+}
+
+func call(function func(), numArgs int16) {
+	push(0)
+	push(RAM[1])
+	push(RAM[2])
+	push(RAM[3])
+	push(RAM[4])
+	RAM[2] = RAM[0] - 5 - numArgs
+	RAM[1] = RAM[0]
+	function()
+}
+
+func ret() {
+	frame := RAM[1]
+	*at(RAM[2]) = pop()
+	RAM[0] = RAM[2] + 1
+	RAM[4] = *at(frame - 1)
+	RAM[3] = *at(frame - 2)
+	RAM[2] = *at(frame - 3)
+	RAM[1] = *at(frame - 4)
+}
+
+func halt() {
+	panic(halted{})
+}
+
+// Native replaces a VM function with Go code. The function still gets a
+// frame, so the arguments it receives are the ones the caller pushed.
+func Native(name string, native func(args []int16) int16) error {
+	function, ok := functions[name]
+	if !ok {
+		return fmt.Errorf("Native: function %s is not part of the program", name)
+	}
+	*function = func() {
+		numArgs := RAM[1] - 5 - RAM[2]
+		args := make([]int16, numArgs)
+		for i := range args {
+			args[i] = *at(RAM[2] + int16(i))
+		}
+		push(native(args))
+		ret()
+	}
+	return nil
+}
+
+var keys []int16
+var keyDown bool
+
+// builtinNatives are the OS functions the runtime can replace on request.
+// Keyboard.keyPressed alternates between pressing and releasing the next
+// key given with -keys, which is what Keyboard.readChar waits for.
+var builtinNatives = map[string]func(args []int16) int16{
+	"Math.abs": func(args []int16) int16 {
+		if args[0] < 0 {
+			return -args[0]
+		}
+		return args[0]
+	},
+	"Math.multiply": func(args []int16) int16 { return args[0] * args[1] },
+	"Math.divide": func(args []int16) int16 {
+		if args[1] == 0 {
+			fmt.Println("Error: Math.divide: division by zero")
+			os.Exit(1)
+		}
+		return args[0] / args[1]
+	},
+	"Math.min":    func(args []int16) int16 { return min(args[0], args[1]) },
+	"Math.max":    func(args []int16) int16 { return max(args[0], args[1]) },
+	"Memory.peek": func(args []int16) int16 { return *at(args[0]) },
+	"Memory.poke": func(args []int16) int16 { *at(args[0]) = args[1]; return 0 },
+	"Keyboard.keyPressed": func(args []int16) int16 {
+		if len(keys) == 0 {
+			halt()
+		}
+		keyDown = !keyDown
+		RAM[keyboardBase] = 0
+		if keyDown {
+			RAM[keyboardBase] = keys[0]
+		} else {
+			keys = keys[1:]
+		}
+		return RAM[keyboardBase]
+	},
+}
+
+func run() {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(halted); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	RAM[0] = 256
+	call(*functions[entry], 0)
+}
+
+func printRAM(ranges string) error {
+	for _, part := range strings.Split(ranges, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return fmt.Errorf("invalid RAM range %s", part)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return fmt.Errorf("invalid RAM range %s", part)
+			}
+		}
+		for address := from; address <= to && address < len(RAM); address++ {
+			fmt.Printf("RAM[%d] = %d\n", address, RAM[address])
+		}
+	}
+	return nil
+}
+
+func printScreen() {
+	var screen strings.Builder
+	screen.WriteString("P1\n512 256\n")
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 512; x++ {
+			screen.WriteByte('0' + byte(RAM[screenBase+y*32+x/16]>>(x%16)&1))
+		}
+		screen.WriteByte('\n')
+	}
+	fmt.Print(screen.String())
+}
+
+func main() {
+	ram := flag.String("ram", "", "comma separated RAM ranges to print at exit, e.g. 16-20,2048")
+	screen := flag.Bool("screen", false, "print the screen as a PBM image at exit")
+	native := flag.String("native", "", "comma separated OS functions to run in Go instead of VM code, or \"all\"")
+	input := flag.String("keys", "", "characters to type on the keyboard, one key press each, when Keyboard.keyPressed is native")
+	flag.Parse()
+
+	for _, c := range *input {
+		keys = append(keys, int16(c))
+	}
+
+	var names []string
+	if *native == "all" {
+		for name := range builtinNatives {
+			if _, ok := functions[name]; ok {
+				names = append(names, name)
+			}
+		}
+	} else if *native != "" {
+		names = strings.Split(*native, ",")
+	}
+	for _, name := range names {
+		hook, ok := builtinNatives[name]
+		if !ok {
+			fmt.Printf("Error: no native implementation of %s\n", name)
+			os.Exit(1)
+		}
+		if err := Native(name, hook); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	run()
+
+	if *ram != "" {
+		if err := printRAM(*ram); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *screen {
+		printScreen()
+	}
+}
+`
+
+var goIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// generateGo emits the whole program as one Go source file: the runtime
+// above, a Go function per VM function and the table that connects VM
+// names to them.
+func (vm *VMTranslator) generateGo(lines []string) (string, error) {
+    layout, err := newProgramLayout(lines, nil)
+    if err != nil {
+        return "", err
+    }
+    if _, ok := layout.functions[vm.entry]; !ok {
+        return "", fmt.Errorf("generateGo: entry point %s is not defined", vm.entry)
+    }
+
+    functions := splitFunctions(lines)
+    names := make(map[string]string)
+    used := make(map[string]bool)
+    for _, function := range functions {
+        if function.name == "" {
+            return "", fmt.Errorf("generateGo: commands outside a function: %s", function.lines[0])
+        }
+        name := "vm_" + goIdentifier.ReplaceAllString(function.name, "_")
+        for base, n := name, 2; used[name]; n++ {
+            name = fmt.Sprintf("%s_%d", base, n)
+        }
+        used[name] = true
+        names[function.name] = name
+    }
+
+    var code strings.Builder
+    code.WriteString("// Code generated by the VM translator. DO NOT EDIT.\n\n")
+    code.WriteString(goRuntime)
+    fmt.Fprintf(&code, "\nconst entry = %q\n", vm.entry)
+
+    code.WriteString("\nvar (\n")
+    for _, function := range functions {
+        fmt.Fprintf(&code, "\tfn_%s func()\n", names[function.name])
+    }
+    code.WriteString(")\n\nfunc init() {\n")
+    for _, function := range functions {
+        fmt.Fprintf(&code, "\tfn_%[1]s = %[1]s\n\tfunctions[%[2]q] = &fn_%[1]s\n", names[function.name], function.name)
+    }
+    code.WriteString("}\n")
+
+    for _, function := range functions {
+        body, err := goFunctionBody(layout, names, function)
+        if err != nil {
+            return "", err
+        }
+        fmt.Fprintf(&code, "\n// %s\nfunc %s() {\n%s}\n", function.name, names[function.name], body)
+    }
+
+    formatted, err := format.Source([]byte(code.String()))
+    if err != nil {
+        return "", fmt.Errorf("generateGo: %v", err)
+    }
+    return string(formatted), nil
+}
+
+func goFunctionBody(layout *programLayout, names map[string]string, function vmFunction) (string, error) {
+    labels := make(map[string]bool)
+    for _, line := range function.lines {
+        if args := strings.Fields(line); args[0] == "label" {
+            labels[args[1]] = true
+        }
+    }
+
+    for _, line := range function.lines {
+        args := strings.Fields(line)
+        switch args[0] {
+        case "goto", "if-goto", "ifnot-goto":
+            if !labels[args[1]] {
+                return "", fmt.Errorf("generateGo: %s in %s jumps out of the function", line, function.name)
+            }
+        }
+    }
+
+    // Go rejects labels that are never used and vet reports statements after
+    // a goto or return, so only the commands that can run are emitted, with
+    // the labels a jump among them refers to. A label only makes the code
+    // after it reachable again once a reachable jump refers to it, so the
+    // two are worked out together until neither changes.
+    targets := make(map[string]bool)
+    live := make([]bool, len(function.lines))
+    for changed := true; changed; {
+        changed = false
+        reachable := true
+        for i, line := range function.lines {
+            args := strings.Fields(line)
+            if args[0] == "label" && targets[args[1]] {
+                reachable = true
+            }
+            live[i] = reachable
+            if !reachable {
+                continue
+            }
+            switch args[0] {
+            case "goto", "if-goto", "ifnot-goto":
+                if !isHaltLoop(function.lines, i) && !targets[args[1]] {
+                    targets[args[1]] = true
+                    changed = true
+                }
+            }
+            if args[0] == "goto" || args[0] == "return" {
+                reachable = false
+            }
+        }
+    }
+
+    var body strings.Builder
+    for i, line := range function.lines {
+        if !live[i] {
+            continue
+        }
+        args := strings.Fields(line)
+        var statement string
+
+        switch args[0] {
+        case "push":
+            statement = fmt.Sprintf("push(%s)", goValue(layout, args[1], args[2], fileArg(args, 3)))
+        case "pop":
+            statement = fmt.Sprintf("%s = pop()", goLocation(layout, args[1], args[2], fileArg(args, 3)))
+        case "move":
+            statement = fmt.Sprintf("%s = %s",
+                goLocation(layout, args[3], args[4], fileArg(args, 5)), goValue(layout, args[1], args[2], fileArg(args, 5)))
+        case "add", "sub", "and", "or", "neg", "not", "eq", "gt", "lt":
+            statement = args[0] + "()"
+        case "label":
+            if !targets[args[1]] {
+                continue
+            }
+            fmt.Fprintf(&body, "L%d: // %s\n", layout.labels[args[1]], args[1])
+            continue
+        case "goto":
+            if isHaltLoop(function.lines, i) {
+                statement = "halt()"
+            } else {
+                statement = fmt.Sprintf("goto L%d", layout.labels[args[1]])
+            }
+        case "if-goto":
+            statement = fmt.Sprintf("if pop() != 0 {\n\t\tgoto L%d\n\t}", layout.labels[args[1]])
+        case "ifnot-goto":
+            statement = fmt.Sprintf("if pop() != -1 {\n\t\tgoto L%d\n\t}", layout.labels[args[1]])
+        case "function":
+            if args[1] == haltFunction {
+                statement = "halt()"
+            } else if numLocals := parseInt(args[2]); numLocals > 0 {
+                statement = fmt.Sprintf("locals(%d)", numLocals)
+            } else {
+                continue
+            }
+        case "call":
+            statement = fmt.Sprintf("call(fn_%s, %s)", names[args[1]], args[2])
+        case "return":
+            statement = "ret()\n\treturn"
+        default:
+            continue
+        }
+        fmt.Fprintf(&body, "\t%s\n", statement)
+    }
+    return body.String(), nil
+}
+
+func goLocation(layout *programLayout, segment, index, fileName string) string {
+    switch segment {
+    case "local":
+        return fmt.Sprintf("*at(RAM[1] + %s)", index)
+    case "argument":
+        return fmt.Sprintf("*at(RAM[2] + %s)", index)
+    case "this":
+        return fmt.Sprintf("*at(RAM[3] + %s)", index)
+    case "that":
+        return fmt.Sprintf("*at(RAM[4] + %s)", index)
+    case "temp":
+        return fmt.Sprintf("RAM[%d]", 5+parseInt(index))
+    case "pointer":
+        return fmt.Sprintf("RAM[%d]", 3+parseInt(index))
+    case "static":
+        return fmt.Sprintf("RAM[%d]", layout.statics[fileName+"."+index])
+    }
+    return ""
+}
+
+func goValue(layout *programLayout, segment, index, fileName string) string {
+    if segment == "constant" {
+        return index
+    }
+    return goLocation(layout, segment, index, fileName)
+}
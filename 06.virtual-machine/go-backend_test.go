@@ -0,0 +1,82 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGo writes the program in directory as a Go module, checks it with go
+// vet, runs it and reads back the RAM it prints when it halts.
+func runGo(t *testing.T, directory string, configure func(vm *VMTranslator)) runResult {
+    t.Helper()
+    goTool, err := exec.LookPath("go")
+    if err != nil {
+        t.Skip("no go tool to build the generated program with")
+    }
+
+    lines := prepare(t, directory, configure)
+    vm := NewVMTranslator(directory, true)
+    code, err := vm.generateGo(lines)
+    if err != nil {
+        t.Fatal(err)
+    }
+    module := t.TempDir()
+    files := map[string]string{"Sys.go": code, "go.mod": "module sys\n\ngo 1.21\n"}
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(module, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    vet := exec.Command(goTool, "vet", ".")
+    vet.Dir = module
+    if output, err := vet.CombinedOutput(); err != nil {
+        t.Fatalf("go vet: %v\n%s", err, output)
+    }
+    run := exec.Command(goTool, "run", ".", "-ram", fmt.Sprintf("16-255,%d-%d", heapBase, keyboardBase-1))
+    run.Dir = module
+    output, err := run.Output()
+    if err != nil {
+        t.Fatalf("running the program: %v", err)
+    }
+
+    ram := make(map[int]int16)
+    for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+        var address, value int
+        if _, err := fmt.Sscanf(line, "RAM[%d] = %d", &address, &value); err != nil {
+            t.Fatalf("unexpected output %q", line)
+        }
+        ram[address] = int16(value)
+    }
+    layout, err := newProgramLayout(lines, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    result := runResult{statics: make(map[string]int16)}
+    for address := heapBase; address < keyboardBase; address++ {
+        result.memory = append(result.memory, ram[address])
+    }
+    for name, address := range layout.statics {
+        result.statics[name] = ram[address]
+    }
+    return result
+}
+
+// The generated Go must pass go vet, which rejects the statements that
+// follow a return or goto in VM code, and end in the interpreter's state.
+func TestGoMatchesInterpreter(t *testing.T) {
+    for name, directory := range map[string]func(t *testing.T) string{
+        "basic":   func(t *testing.T) string { return "testdata/basic" },
+        "objects": func(t *testing.T) string { return jackProgram(t, "objects") },
+    } {
+        t.Run(name, func(t *testing.T) {
+            directory := directory(t)
+            want := interpret(t, prepare(t, directory, nil), "Sys.init")
+            compareRuns(t, want, runGo(t, directory, nil))
+        })
+    }
+}
@@ -0,0 +1,206 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+    stackLimit   = 2048
+    keyboardAddr = 24576
+)
+
+type guardSite struct {
+    kind     string
+    function string
+    line     string
+}
+
+// stackNeeds returns how many words a command takes off the stack and how
+// many it adds on top of it. A call also counts the frame and the callee's
+// locals, which are pushed before the callee runs its first command.
+func stackNeeds(line string, numLocals map[string]int) (int, int) {
+    args := strings.Fields(line)
+    switch args[0] {
+    case "push":
+        return 0, 1
+    case "pop", "if-goto", "ifnot-goto", "return", "neg", "not":
+        return 1, 0
+    case "add", "sub", "and", "or", "eq", "gt", "lt":
+        return 2, 0
+    case "call":
+        return parseInt(args[2]), 5 + numLocals[args[1]]
+    }
+    return 0, 0
+}
+
+func (vm *VMTranslator) addGuardSite(kind, function, line string) string {
+    vm.guardSites = append(vm.guardSites, guardSite{kind: kind, function: function, line: line})
+    return fmt.Sprintf("GUARD.%d", vm.guardBase+len(vm.guardSites))
+}
+
+// writeGuards emits the run-time checks for one command of a function. Each
+// failing check jumps to its own site label; the site table is written next
+// to the .asm file so the number left in R15 can be traced back to the VM
+// function and command that caused it.
+func (vm *VMTranslator) writeGuards(line, function string, frameSize int, numLocals map[string]int) string {
+    if function == "" {
+        return ""
+    }
+
+    var code string
+    pops, pushes := stackNeeds(line, numLocals)
+    if pops > 0 {
+        code += fmt.Sprintf(`
+			@SP
+			D=M
+			@LCL
+			D=D-M
+			@%d
+			D=D-A
+			@%s
+			D;JLT`, frameSize+pops, vm.addGuardSite("pop below the frame base", function, line))
+    }
+    if pushes > 0 {
+        code += fmt.Sprintf(`
+			@SP
+			D=M
+			@%d
+			D=D-A
+			@%s
+			D;JGT`, stackLimit-pushes, vm.addGuardSite("stack overflow past RAM 2047", function, line))
+    }
+
+    args := strings.Fields(line)
+    switch args[0] {
+    case "push", "pop":
+        code += vm.writeAddressGuard(args[1], args[2], args[0] == "pop", function, line)
+    case "move":
+        code += vm.writeAddressGuard(args[1], args[2], false, function, line)
+        code += vm.writeAddressGuard(args[3], args[4], true, function, line)
+    }
+
+    if code == "" {
+        return ""
+    }
+    return "// guard " + line + code
+}
+
+// writeAddressGuard checks that a this/that access stays inside RAM and the
+// memory-mapped screen and keyboard. Addresses past 32767 wrap to negative
+// numbers, so one signed test catches them together with index overflow.
+func (vm *VMTranslator) writeAddressGuard(segment, index string, write bool, function, line string) string {
+    if segment != "this" && segment != "that" {
+        return ""
+    }
+    pointer := "THIS"
+    if segment == "that" {
+        pointer = "THAT"
+    }
+    limit := keyboardAddr
+    if write {
+        limit--
+    }
+
+    return fmt.Sprintf(`
+			@%s
+			D=M
+			@%s
+			D=D+A
+			@%s
+			D;JLT
+			@%d
+			D=D-A
+			@%s
+			D;JGT`, pointer, index,
+        vm.addGuardSite(segment+" access outside RAM", function, line), limit,
+        vm.addGuardSite(segment+" access past the memory map", function, line))
+}
+
+func (vm *VMTranslator) writeGuardHandlers() []string {
+    var code []string
+    for i := range vm.guardSites {
+        code = append(code, fmt.Sprintf(`(GUARD.%d)
+			@%d
+			D=A
+			@GUARD.FAIL
+			0;JMP`, i+1, i+1))
+    }
+    code = append(code, `// a guard failed: R15 holds the site number from the .guards file
+		(GUARD.FAIL)
+			@R15
+			M=D
+		(GUARD.HALT)
+			@GUARD.HALT
+			0;JMP`)
+    return code
+}
+
+func (vm *VMTranslator) guardReport() string {
+    var report strings.Builder
+    for i, site := range vm.guardSites {
+        fmt.Fprintf(&report, "%d\t%s\t%s\t%s\n", i+1, site.function, site.line, site.kind)
+    }
+    return report.String()
+}
+
+// checkGuards applies the same checks as the -guards translation before the
+// interpreter executes a command.
+func (in *Interpreter) checkGuards() error {
+    owner := in.owners[in.pc]
+    if owner == -1 {
+        return nil
+    }
+
+    line := in.lines[in.pc]
+    function := in.commands[owner].name
+    fail := func(kind string) error {
+        return fmt.Errorf("%s in %s (%s)", kind, function, line)
+    }
+
+    pops, pushes := stackNeeds(line, in.numLocals)
+    if pops > 0 && in.RAM[0]-int16(pops) < in.RAM[1]+int16(in.commands[owner].index) {
+        return fail("pop below the frame base")
+    }
+    if int(in.RAM[0])+pushes > stackLimit {
+        return fail("stack overflow past RAM 2047")
+    }
+
+    command := &in.commands[in.pc]
+    switch command.kind {
+    case "C_PUSH", "C_POP":
+        return in.checkAddress(command.segment, command.index, command.kind == "C_POP", fail)
+    case "C_MOVE":
+        if err := in.checkAddress(command.segment, command.index, false, fail); err != nil {
+            return err
+        }
+        return in.checkAddress(command.destSegment, command.destIndex, true, fail)
+    }
+    return nil
+}
+
+func (in *Interpreter) checkAddress(segment string, index int, write bool, fail func(string) error) error {
+    if segment != "this" && segment != "that" {
+        return nil
+    }
+    limit := keyboardAddr
+    if write {
+        limit--
+    }
+
+    address := int(in.segmentAddress(segment, index, -1))
+    if address < 0 {
+        return fail(segment + " access outside RAM")
+    }
+    if address > limit {
+        return fail(segment + " access past the memory map")
+    }
+    return nil
+}
+
+func (in *Interpreter) EnableGuards() {
+    in.numLocals = make(map[string]int)
+    for name, start := range in.functions {
+        in.numLocals[name] = in.commands[start].index
+    }
+}
@@ -0,0 +1,195 @@
+package vmtranslator
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	assembler "nand2tetris/05.assembler"
+)
+
+// hackCPU runs translated programs the way the CPU emulator of the course
+// does, counting instructions and recording the deepest stack.
+type hackCPU struct {
+    rom     []uint16
+    RAM     [32768]int16
+    halts   map[int]bool
+    cycles  int
+    maxSP   int16
+}
+
+var predefinedSymbols = map[string]bool{
+    "SP": true, "LCL": true, "ARG": true, "THIS": true, "THAT": true,
+    "SCREEN": true, "KBD": true,
+}
+
+// emulate translates the program in directory with the bootstrap code
+// calling entry and runs it until entry returns, Sys.halt is called or the
+// program jumps to itself.
+func emulate(t *testing.T, directory, entry string, configure func(vm *VMTranslator)) (runResult, *hackCPU) {
+    t.Helper()
+    vm := NewVMTranslator(directory, true)
+    vm.entry = entry
+    if configure != nil {
+        configure(vm)
+    }
+    vm.loadBootstrapCode()
+    // The bootstrap code runs into the first function when entry returns;
+    // stop there instead.
+    vm.code[0] += "\n@" + entry + "$RETURN0\n0;JMP"
+    if err := vm.translate(); err != nil {
+        t.Fatal(err)
+    }
+    source := strings.Join(vm.code, "\n")
+
+    binary, err := assembler.Assemble("Sys.asm", source)
+    if err != nil {
+        t.Fatal(err)
+    }
+    cpu := &hackCPU{halts: make(map[int]bool)}
+    for _, line := range strings.Fields(binary) {
+        word, err := strconv.ParseUint(line, 2, 16)
+        if err != nil || len(line) != 16 {
+            t.Fatalf("assembler wrote %q", line)
+        }
+        cpu.rom = append(cpu.rom, uint16(word))
+    }
+    if len(cpu.rom) > 1<<15 {
+        t.Fatalf("the program is %d instructions, more than the %d words of ROM", len(cpu.rom), 1<<15)
+    }
+
+    labels, variables := hackSymbols(source)
+    if address, ok := labels[haltFunction]; ok {
+        cpu.halts[address] = true
+    }
+    pc := cpu.run(t, 200_000_000)
+
+    result := runResult{
+        hasReturn: pc == labels[entry+"$RETURN0"]+1,
+        statics:   make(map[string]int16),
+        memory:    append([]int16{}, cpu.RAM[heapBase:keyboardBase]...),
+    }
+    if result.hasReturn {
+        result.returned = cpu.RAM[cpu.RAM[0]-1]
+    }
+    for name, address := range variables {
+        result.statics[name] = cpu.RAM[address]
+    }
+    return result, cpu
+}
+
+// hackSymbols assigns addresses the way the assembler does: labels get the
+// address of the next instruction, other symbols a RAM word from 16 on in
+// order of first use.
+func hackSymbols(source string) (map[string]int, map[string]int) {
+    labels := make(map[string]int)
+    var lines []string
+    for _, line := range strings.Split(source, "\n") {
+        if i := strings.Index(line, "//"); i != -1 {
+            line = line[:i]
+        }
+        line = strings.TrimSpace(line)
+        switch {
+        case line == "":
+        case strings.HasPrefix(line, "("):
+            labels[strings.Trim(line, "()")] = len(lines)
+        default:
+            lines = append(lines, line)
+        }
+    }
+
+    variables := make(map[string]int)
+    next := 16
+    for _, line := range lines {
+        symbol, ok := strings.CutPrefix(line, "@")
+        if !ok || predefinedSymbols[symbol] || (symbol[0] >= '0' && symbol[0] <= '9') {
+            continue
+        }
+        if _, ok := labels[symbol]; ok {
+            continue
+        }
+        if n, err := strconv.Atoi(strings.TrimPrefix(symbol, "R")); err == nil && symbol[0] == 'R' && n < 16 {
+            continue
+        }
+        if _, ok := variables[symbol]; !ok {
+            variables[symbol] = next
+            next++
+        }
+    }
+    return labels, variables
+}
+
+// run executes instructions until the program reaches a halt address or
+// jumps to itself, and returns the final program counter.
+func (cpu *hackCPU) run(t *testing.T, maxCycles int) int {
+    t.Helper()
+    var a, d int16
+    pc := 0
+    for ; cpu.cycles < maxCycles; cpu.cycles++ {
+        if cpu.halts[pc] {
+            return pc
+        }
+        if pc < 0 || pc >= len(cpu.rom) {
+            t.Fatalf("program counter %d is outside the program", pc)
+        }
+        if sp := cpu.RAM[0]; sp > cpu.maxSP {
+            cpu.maxSP = sp
+        }
+
+        instruction := cpu.rom[pc]
+        if instruction&0x8000 == 0 {
+            a = int16(instruction)
+            pc++
+            continue
+        }
+
+        comp := instruction >> 6 & 0x3f
+        x, y := d, a
+        if instruction&0x1000 != 0 {
+            y = cpu.RAM[uint16(a)&0x7fff]
+        }
+        if comp&0x20 != 0 {
+            x = 0
+        }
+        if comp&0x10 != 0 {
+            x = ^x
+        }
+        if comp&0x08 != 0 {
+            y = 0
+        }
+        if comp&0x04 != 0 {
+            y = ^y
+        }
+        out := x & y
+        if comp&0x02 != 0 {
+            out = x + y
+        }
+        if comp&0x01 != 0 {
+            out = ^out
+        }
+
+        target := int(uint16(a))
+        address := uint16(a) & 0x7fff
+        if instruction&0x08 != 0 {
+            cpu.RAM[address] = out
+        }
+        if instruction&0x20 != 0 {
+            a = out
+        }
+        if instruction&0x10 != 0 {
+            d = out
+        }
+
+        jump := instruction & 0x07
+        if (jump&0x04 != 0 && out < 0) || (jump&0x02 != 0 && out == 0) || (jump&0x01 != 0 && out > 0) {
+            if target == pc-1 {
+                return pc
+            }
+            pc = target
+        } else {
+            pc++
+        }
+    }
+    t.Fatalf("no halt after %d instructions", maxCycles)
+    return pc
+}
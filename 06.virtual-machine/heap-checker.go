@@ -0,0 +1,172 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var osClasses = map[string]bool{
+    "Array":    true,
+    "Keyboard": true,
+    "Math":     true,
+    "Memory":   true,
+    "Output":   true,
+    "Screen":   true,
+    "String":   true,
+    "Sys":      true,
+}
+
+type allocation struct {
+    size int16
+    site string
+}
+
+// heapChecker follows every Memory.alloc and Memory.deAlloc the program
+// makes, whether the OS runs as VM code or natively, and remembers which
+// heap words belong to a live block.
+type heapChecker struct {
+    live      map[int16]allocation
+    freed     map[int16]string
+    owned     [screenBase - heapBase]bool
+    pending   []allocation
+    problems  []string
+    counts    map[string]int
+}
+
+func (in *Interpreter) CheckHeap() {
+    in.heap = &heapChecker{
+        live:   make(map[int16]allocation),
+        freed:  make(map[int16]string),
+        counts: make(map[string]int),
+    }
+}
+
+// callSite names the innermost frame that is not running OS code, so an
+// allocation made by String.new on behalf of Main.main is blamed on Main.main.
+func (in *Interpreter) callSite() string {
+    frames, _ := in.Frames()
+    for _, frame := range frames {
+        class, _, _ := strings.Cut(frame.Function, ".")
+        if !osClasses[class] {
+            return frame.Function + " " + in.commandAt(frame.Command)
+        }
+    }
+    return in.CurrentFunction() + " " + in.commandAt(in.pc)
+}
+
+func (hc *heapChecker) report(problem string) {
+    if hc.counts[problem] == 0 {
+        hc.problems = append(hc.problems, problem)
+    }
+    hc.counts[problem]++
+}
+
+func (in *Interpreter) checkCall(name string, args []int16) {
+    if in.heap == nil {
+        return
+    }
+
+    switch name {
+    case "Memory.alloc":
+        in.heap.pending = append(in.heap.pending, allocation{size: args[0], site: in.callSite()})
+    case "Memory.deAlloc":
+        in.heap.free(args[0], in.callSite())
+    }
+}
+
+func (in *Interpreter) checkReturn(name string, result int16) {
+    if in.heap == nil || name != "Memory.alloc" || len(in.heap.pending) == 0 {
+        return
+    }
+
+    hc := in.heap
+    block := hc.pending[len(hc.pending)-1]
+    hc.pending = hc.pending[:len(hc.pending)-1]
+    if result < heapBase || result+block.size > screenBase {
+        hc.report(fmt.Sprintf("Memory.alloc(%d) returned %d, outside the heap, in %s", block.size, result, block.site))
+        return
+    }
+
+    hc.live[result] = block
+    delete(hc.freed, result)
+    for address := result; address < result+block.size; address++ {
+        hc.owned[address-heapBase] = true
+    }
+}
+
+func (hc *heapChecker) free(pointer int16, site string) {
+    block, ok := hc.live[pointer]
+    if !ok {
+        if freedAt, ok := hc.freed[pointer]; ok {
+            hc.report(fmt.Sprintf("double free of %d in %s, already freed in %s", pointer, site, freedAt))
+        } else {
+            hc.report(fmt.Sprintf("free of %d, which was never allocated, in %s", pointer, site))
+        }
+        return
+    }
+
+    delete(hc.live, pointer)
+    hc.freed[pointer] = site
+    for address := pointer; address < pointer+block.size; address++ {
+        hc.owned[address-heapBase] = false
+    }
+}
+
+// checkWrite flags this/that writes into heap words that are not part of a
+// live block. The Memory class itself maintains the block headers and is
+// allowed to write anywhere.
+func (in *Interpreter) checkWrite(segment string, index int) {
+    if in.heap == nil || (segment != "this" && segment != "that") {
+        return
+    }
+    address := in.segmentAddress(segment, index, -1)
+    if address < heapBase || address >= screenBase || in.heap.owned[address-heapBase] {
+        return
+    }
+    if class, _, _ := strings.Cut(in.CurrentFunction(), "."); class == "Memory" {
+        return
+    }
+    in.heap.report(fmt.Sprintf("write outside any allocated block in %s %s", in.CurrentFunction(), in.commandAt(in.pc)))
+}
+
+func (in *Interpreter) printHeapReport(w io.Writer) {
+    hc := in.heap
+
+    type leak struct {
+        site   string
+        blocks int
+        words  int
+    }
+    leaks := make(map[string]*leak)
+    for _, block := range hc.live {
+        if leaks[block.site] == nil {
+            leaks[block.site] = &leak{site: block.site}
+        }
+        leaks[block.site].blocks++
+        leaks[block.site].words += int(block.size)
+    }
+    sorted := make([]*leak, 0, len(leaks))
+    for _, l := range leaks {
+        sorted = append(sorted, l)
+    }
+    sort.Slice(sorted, func(i, j int) bool {
+        if sorted[i].words != sorted[j].words {
+            return sorted[i].words > sorted[j].words
+        }
+        return sorted[i].site < sorted[j].site
+    })
+
+    fmt.Fprintf(w, "Heap check: %d blocks still allocated\n", len(hc.live))
+    for _, l := range sorted {
+        fmt.Fprintf(w, "  leaked %d blocks (%d words) allocated in %s\n", l.blocks, l.words, l.site)
+    }
+    for _, problem := range hc.problems {
+        if count := hc.counts[problem]; count > 1 {
+            fmt.Fprintf(w, "  %s (%d times)\n", problem, count)
+        } else {
+            fmt.Fprintf(w, "  %s\n", problem)
+        }
+    }
+}
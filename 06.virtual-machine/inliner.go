@@ -0,0 +1,286 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type inlineCandidate struct {
+    name      string
+    numLocals int
+    body      []string
+    usedTemps map[int]bool
+    pointers  []string
+    hasCalls  bool
+}
+
+func (vm *VMTranslator) inlineFunctions(lines []string) []string {
+    functions := splitFunctions(lines)
+    graph := buildCallGraph(functions)
+
+    candidates := make(map[string]*inlineCandidate)
+    for _, function := range functions {
+        if function.name == "" || len(function.lines)-1 > vm.inlineThreshold {
+            continue
+        }
+        // The interpreter and the C, Go and ELF backends stop when Sys.halt
+        // is called; inlined, its endless loop would never be noticed.
+        if function.name == haltFunction {
+            continue
+        }
+        if reachableFunctions(graph, graph[function.name])[function.name] {
+            continue
+        }
+        if candidate := newInlineCandidate(function); candidate != nil {
+            candidates[function.name] = candidate
+        }
+    }
+
+    inlined := make(map[string]int)
+    var result []string
+    for _, function := range functions {
+        for _, line := range function.lines {
+            args := strings.Fields(line)
+            if args[0] == "call" {
+                if candidate, ok := candidates[args[1]]; ok {
+                    if body := vm.expandCall(candidate, parseInt(args[2])); body != nil {
+                        result = append(result, body...)
+                        inlined[args[1]]++
+                        continue
+                    }
+                }
+            }
+            result = append(result, line)
+        }
+    }
+
+    vm.reportInlining(inlined)
+    return result
+}
+
+func newInlineCandidate(function vmFunction) *inlineCandidate {
+    candidate := &inlineCandidate{
+        name:      function.name,
+        numLocals: parseInt(strings.Fields(function.lines[0])[2]),
+        body:      function.lines[1:],
+        usedTemps: make(map[int]bool),
+    }
+
+    writesPointer := make(map[string]bool)
+    hasLabels := false
+    for _, line := range candidate.body {
+        args := strings.Fields(line)
+        switch {
+        case len(args) >= 3 && args[1] == "temp":
+            candidate.usedTemps[parseInt(args[2])] = true
+        case args[0] == "pop" && args[1] == "pointer":
+            writesPointer[args[2]] = true
+        case args[0] == "call":
+            candidate.hasCalls = true
+        case args[0] == "label":
+            hasLabels = true
+        }
+    }
+    for _, pointer := range []string{"0", "1"} {
+        if writesPointer[pointer] {
+            candidate.pointers = append(candidate.pointers, pointer)
+        }
+    }
+
+    if !stackBalanced(candidate.body) {
+        return nil
+    }
+
+    // Temps are not preserved across calls, so a body that calls out may
+    // only keep its arguments and locals in temps between calls.
+    if candidate.hasCalls && (hasLabels || len(candidate.pointers) > 0 || !tempsSurviveCalls(candidate.body)) {
+        return nil
+    }
+    return candidate
+}
+
+func stackEffect(line string) int {
+    args := strings.Fields(line)
+    switch args[0] {
+    case "push":
+        return 1
+    case "pop", "if-goto", "ifnot-goto":
+        return -1
+    case "call":
+        return 1 - parseInt(args[2])
+    case "add", "sub", "and", "or", "eq", "gt", "lt":
+        return -1
+    }
+    return 0
+}
+
+// stackBalanced reports whether every path through the body leaves exactly
+// the return value on the stack when it reaches a return.
+func stackBalanced(body []string) bool {
+    labelDepth := make(map[string]int)
+    record := func(label string, depth int) bool {
+        if known, ok := labelDepth[label]; ok {
+            return known == depth
+        }
+        labelDepth[label] = depth
+        return true
+    }
+
+    depth := 0
+    reachable := true
+    for _, line := range body {
+        args := strings.Fields(line)
+        switch args[0] {
+        case "label":
+            if !reachable {
+                known, ok := labelDepth[args[1]]
+                if !ok {
+                    return false
+                }
+                depth = known
+            }
+            if !record(args[1], depth) {
+                return false
+            }
+            reachable = true
+            continue
+        case "function":
+            return false
+        }
+
+        if !reachable {
+            continue
+        }
+
+        depth += stackEffect(line)
+        if depth < 0 {
+            return false
+        }
+
+        switch args[0] {
+        case "goto":
+            reachable = false
+            if !record(args[1], depth) {
+                return false
+            }
+        case "if-goto", "ifnot-goto":
+            if !record(args[1], depth) {
+                return false
+            }
+        case "return":
+            if depth != 1 {
+                return false
+            }
+            reachable = false
+        }
+    }
+    return !reachable
+}
+
+func tempsSurviveCalls(body []string) bool {
+    valid := make(map[string]bool)
+    clobbered := false
+
+    for _, line := range body {
+        args := strings.Fields(line)
+        if args[0] == "call" {
+            clobbered = true
+            valid = make(map[string]bool)
+            continue
+        }
+        if len(args) < 3 || (args[1] != "argument" && args[1] != "local") {
+            continue
+        }
+
+        variable := args[1] + " " + args[2]
+        if args[0] == "pop" {
+            valid[variable] = true
+        } else if clobbered && !valid[variable] {
+            return false
+        }
+    }
+    return true
+}
+
+func (vm *VMTranslator) expandCall(candidate *inlineCandidate, numArgs int) []string {
+    var free []int
+    for i := 7; i >= 0; i-- {
+        if !candidate.usedTemps[i] {
+            free = append(free, i)
+        }
+    }
+    if numArgs+candidate.numLocals+len(candidate.pointers) > len(free) {
+        return nil
+    }
+
+    argumentTemps := free[:numArgs]
+    localTemps := free[numArgs : numArgs+candidate.numLocals]
+    pointerTemps := free[numArgs+candidate.numLocals:]
+
+    vm.inlineCounter++
+    suffix := "$inline" + strconv.Itoa(vm.inlineCounter)
+    end := candidate.name + "$return" + suffix
+
+    var code []string
+    for i := numArgs - 1; i >= 0; i-- {
+        code = append(code, fmt.Sprintf("pop temp %d", argumentTemps[i]))
+    }
+    for i, pointer := range candidate.pointers {
+        code = append(code, "push pointer "+pointer, fmt.Sprintf("pop temp %d", pointerTemps[i]))
+    }
+    for _, temp := range localTemps {
+        code = append(code, "push constant 0", fmt.Sprintf("pop temp %d", temp))
+    }
+
+    for i, line := range candidate.body {
+        args := strings.Fields(line)
+        switch args[0] {
+        case "label", "goto", "if-goto", "ifnot-goto":
+            code = append(code, args[0]+" "+args[1]+suffix)
+        case "return":
+            if i != len(candidate.body)-1 {
+                code = append(code, "goto "+end)
+            }
+        case "push", "pop":
+            index := parseInt(args[2])
+            switch args[1] {
+            case "argument":
+                if index >= numArgs {
+                    return nil
+                }
+                code = append(code, fmt.Sprintf("%s temp %d", args[0], argumentTemps[index]))
+            case "local":
+                if index >= candidate.numLocals {
+                    return nil
+                }
+                code = append(code, fmt.Sprintf("%s temp %d", args[0], localTemps[index]))
+            default:
+                code = append(code, line)
+            }
+        default:
+            code = append(code, line)
+        }
+    }
+
+    code = append(code, "label "+end)
+    for i, pointer := range candidate.pointers {
+        code = append(code, fmt.Sprintf("push temp %d", pointerTemps[i]), "pop pointer "+pointer)
+    }
+    return code
+}
+
+func (vm *VMTranslator) reportInlining(inlined map[string]int) {
+    names := make([]string, 0, len(inlined))
+    for name := range inlined {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    fmt.Fprintf(os.Stderr, "Inlined %d functions:\n", len(names))
+    for _, name := range names {
+        fmt.Fprintf(os.Stderr, "  %-32s %6d call sites\n", name, inlined[name])
+    }
+}
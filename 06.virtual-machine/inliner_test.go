@@ -0,0 +1,221 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+func inline(vm *VMTranslator) {
+    vm.inline = true
+}
+
+// Each program calls functions that the inliner either has to expand with
+// care or has to leave alone; the inlined program must leave the same
+// statics, heap and return value as the original, in the interpreter and in
+// translated code.
+func TestInlinerPreservesBehaviour(t *testing.T) {
+    tests := []struct {
+        name    string
+        source  string
+        present []string
+        absent  []string
+    }{
+        {
+            // mix uses temps 0 and 7 itself, so its arguments and local
+            // must go to the free temps counted down from 6.
+            name: "free temps",
+            source: `function Main.test 0
+push constant 9
+push constant 4
+call Main.mix 2
+return
+function Main.mix 1
+push argument 0
+push argument 1
+sub
+pop local 0
+push local 0
+pop temp 7
+push temp 7
+push argument 1
+add
+pop temp 0
+push temp 0
+push local 0
+add
+return
+`,
+            present: []string{"pop temp 5", "pop temp 6", "pop temp 4"},
+            absent:  []string{"call Main.mix"},
+        },
+        {
+            // store moves THAT; the caller's THAT must be back in place
+            // when the inlined body is done, as after a real return.
+            name: "pointer save and restore",
+            source: `function Main.test 0
+push constant 3000
+pop pointer 1
+push constant 4000
+push constant 5
+call Main.store 2
+pop static 0
+push constant 7
+pop that 0
+push constant 4000
+pop pointer 1
+push that 0
+push static 0
+add
+return
+function Main.store 0
+push argument 0
+pop pointer 1
+push argument 1
+pop that 0
+push argument 1
+return
+`,
+            present: []string{"push pointer 1", "pop pointer 1"},
+            absent:  []string{"call Main.store"},
+        },
+        {
+            // quad calls out, but reads its argument before the first call,
+            // so the temps holding it may be clobbered afterwards.
+            name: "body with calls",
+            source: `function Main.test 0
+push constant 3
+call Main.quad 1
+return
+function Main.quad 0
+push argument 0
+call Main.double 1
+call Main.double 1
+return
+function Main.double 0
+push argument 0
+push argument 0
+add
+return
+`,
+            absent: []string{"call Main.quad"},
+        },
+        {
+            // One path of pick returns with an extra value on the stack,
+            // which a real return discards and an inlined body would not.
+            name: "unbalanced stack",
+            source: `function Main.test 0
+push constant 0
+call Main.pick 1
+push constant 1
+call Main.pick 1
+add
+return
+function Main.pick 0
+push argument 0
+if-goto EXTRA
+push constant 10
+return
+label EXTRA
+push constant 20
+push constant 30
+return
+`,
+            present: []string{"call Main.pick"},
+        },
+        {
+            // twice reads its argument after a call, when the temp holding
+            // it may have been reused, so it must stay a real function.
+            name: "temps used across calls",
+            source: `function Main.test 0
+push constant 6
+call Main.twice 1
+return
+function Main.twice 0
+push argument 0
+call Main.clobber 1
+push argument 0
+add
+return
+function Main.clobber 0
+push constant 100
+pop temp 7
+push argument 0
+return
+`,
+            present: []string{"call Main.twice"},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            directory := writeProgram(t, map[string]string{"Main.vm": test.source})
+            lines := prepare(t, directory, inline)
+            for _, prefix := range test.present {
+                if !hasCommand(lines, prefix) {
+                    t.Errorf("inlined program has no %q:\n%s", prefix, strings.Join(lines, "\n"))
+                }
+            }
+            for _, prefix := range test.absent {
+                if hasCommand(lines, prefix) {
+                    t.Errorf("inlined program still has %q:\n%s", prefix, strings.Join(lines, "\n"))
+                }
+            }
+
+            want := interpret(t, prepare(t, directory, nil), "Main.test")
+            compareRuns(t, want, interpret(t, lines, "Main.test"))
+            inlined, _ := emulate(t, directory, "Main.test", inline)
+            compareRuns(t, want, inlined)
+        })
+    }
+}
+
+func TestInlinerGuards(t *testing.T) {
+    tests := []struct {
+        body     string
+        balanced bool
+        survive  bool
+    }{
+        {"push argument 0\nreturn", true, true},
+        {"push argument 0\nif-goto A\npush constant 1\nreturn\nlabel A\npush constant 2\nreturn", true, true},
+        {"push argument 0\nif-goto A\npush constant 1\nreturn\nlabel A\npush constant 2\npush constant 3\nreturn", false, true},
+        {"push argument 0\nif-goto A\npush constant 1\nlabel A\npush constant 2\nreturn", false, true},
+        {"push constant 1\npop local 0\ncall Main.f 0\npop temp 0\npush local 0\nreturn", true, false},
+        {"call Main.f 0\npop local 0\npush local 0\nreturn", true, true},
+        {"push argument 0\ncall Main.f 1\nreturn", true, true},
+    }
+
+    for _, test := range tests {
+        body := strings.Split(test.body, "\n")
+        if got := stackBalanced(body); got != test.balanced {
+            t.Errorf("stackBalanced(%q) = %v, want %v", test.body, got, test.balanced)
+        }
+        if got := tempsSurviveCalls(body); got != test.survive {
+            t.Errorf("tempsSurviveCalls(%q) = %v, want %v", test.body, got, test.survive)
+        }
+    }
+}
+
+func TestInlinerPrograms(t *testing.T) {
+    for name, directory := range map[string]func(t *testing.T) string{
+        "basic":   func(t *testing.T) string { return "testdata/basic" },
+        "objects": func(t *testing.T) string { return jackProgram(t, "objects") },
+    } {
+        t.Run(name, func(t *testing.T) {
+            directory := directory(t)
+            lines := prepare(t, directory, inline)
+            if !hasCommand(lines, "pop temp") {
+                t.Fatalf("nothing was inlined")
+            }
+            want := interpret(t, prepare(t, directory, nil), "Sys.init")
+            compareRuns(t, want, interpret(t, lines, "Sys.init"))
+            // Inlining into the whole OS grows the program past the 32K
+            // words of ROM, so translated code also drops the functions
+            // that are no longer called.
+            inlined, _ := emulate(t, directory, "Sys.init", func(vm *VMTranslator) {
+                vm.inline = true
+                vm.removeDead = true
+            })
+            compareRuns(t, want, inlined)
+        })
+    }
+}
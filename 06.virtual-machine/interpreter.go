@@ -0,0 +1,471 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+    stackBase      = 256
+    heapBase       = 2048
+    screenBase     = 16384
+    keyboardBase   = 24576
+    returnSentinel = -1
+    nestedReturn   = -2
+    haltFunction   = "Sys.halt"
+
+    // Return addresses are stored in a frame as unsigned 16-bit words and
+    // the two highest values are the sentinels above, so a program can have
+    // at most this many commands.
+    maxCommands = 1<<16 - 3
+)
+
+func NewInterpreter(directory string) (*Interpreter, error) {
+    vm := NewVMTranslator(directory, true)
+    if info, err := os.Stat(directory); err == nil && !info.IsDir() {
+        vm = NewVMTranslator(directory, false)
+        if err := vm.parse(); err != nil {
+            return nil, err
+        }
+    } else if err := vm.parseDirectory(directory); err != nil {
+        return nil, err
+    }
+    if len(vm.parsedContent) == 0 {
+        return nil, fmt.Errorf("NewInterpreter: no .vm files in %s", directory)
+    }
+    return NewInterpreterFromLines(vm.parsedContent)
+}
+
+func NewInterpreterFromLines(lines []string) (*Interpreter, error) {
+    if len(lines) > maxCommands {
+        return nil, fmt.Errorf("NewInterpreter: program has %d commands, more than the %d a return address can hold", len(lines), maxCommands)
+    }
+    in := &Interpreter{
+        lines:     lines,
+        commands:  make([]vmCommand, len(lines)),
+        functions: make(map[string]int),
+        owners:    make([]int, len(lines)),
+        statics:   make(map[string]int),
+        natives:   make(map[string]NativeFunction),
+    }
+
+    vm := NewVMTranslator("", false)
+    labels := make(map[string]int)
+    owner := -1
+    nextStatic := 16
+
+    staticAddress := func(file, index string) int {
+        name := file + "." + index
+        if _, ok := in.statics[name]; !ok {
+            in.statics[name] = nextStatic
+            nextStatic++
+        }
+        return in.statics[name]
+    }
+
+    for i, line := range lines {
+        args := strings.Fields(line)
+        command := vmCommand{kind: vm.commandType(line), target: -1}
+
+        switch command.kind {
+        case "C_PUSH", "C_POP":
+            command.segment = args[1]
+            command.index = parseInt(args[2])
+            if len(args) > 3 {
+                command.file = args[3]
+            }
+            if command.segment == "static" {
+                command.address = staticAddress(command.file, args[2])
+            }
+        case "C_MOVE":
+            command.segment = args[1]
+            command.index = parseInt(args[2])
+            command.destSegment = args[3]
+            command.destIndex = parseInt(args[4])
+            if len(args) > 5 {
+                command.file = args[5]
+            }
+            if command.segment == "static" {
+                command.address = staticAddress(command.file, args[2])
+            }
+            if command.destSegment == "static" {
+                command.destAddress = staticAddress(command.file, args[4])
+            }
+        case "C_ARITHMETIC":
+            command.segment = line
+        case "C_LABEL":
+            command.name = args[1]
+            labels[args[1]] = i
+        case "C_GOTO", "C_IF", "C_IF_NOT":
+            command.name = args[1]
+        case "C_FUNCTION":
+            command.name = args[1]
+            command.index = parseInt(args[2])
+            in.functions[args[1]] = i
+            owner = i
+        case "C_CALL":
+            command.name = args[1]
+            command.index = parseInt(args[2])
+        }
+
+        in.commands[i] = command
+        in.owners[i] = owner
+    }
+
+    for i := range in.commands {
+        command := &in.commands[i]
+        switch command.kind {
+        case "C_GOTO", "C_IF", "C_IF_NOT":
+            target, ok := labels[command.name]
+            if !ok {
+                return nil, fmt.Errorf("NewInterpreter: label %s is not defined", command.name)
+            }
+            command.target = target
+        case "C_CALL":
+            if target, ok := in.functions[command.name]; ok {
+                command.target = target
+            }
+        }
+    }
+
+    return in, nil
+}
+
+func (in *Interpreter) Boot(entry string) error {
+    target, ok := in.functions[entry]
+    if !ok {
+        return fmt.Errorf("Boot: entry point %s is not defined", entry)
+    }
+
+    in.RAM[0] = stackBase
+    in.pushFrame(returnSentinel, 0)
+    in.pc = target
+    in.halted = false
+    return nil
+}
+
+func (in *Interpreter) Run(entry string) error {
+    if err := in.Boot(entry); err != nil {
+        return err
+    }
+
+    for !in.halted {
+        if in.MaxSteps > 0 && in.steps >= in.MaxSteps {
+            return fmt.Errorf("Run: step limit of %d reached in %s", in.MaxSteps, in.CurrentFunction())
+        }
+        if err := in.Step(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (in *Interpreter) Step() error {
+    if in.halted {
+        return nil
+    }
+    if in.pc < 0 || in.pc >= len(in.commands) {
+        return fmt.Errorf("Step: program counter %d is outside the program", in.pc)
+    }
+
+    if in.numLocals != nil {
+        if err := in.checkGuards(); err != nil {
+            return fmt.Errorf("Step: %v", err)
+        }
+    }
+
+    command := &in.commands[in.pc]
+    next := in.pc + 1
+    in.steps++
+
+    switch command.kind {
+    case "C_PUSH":
+        in.push(in.load(command.segment, command.index, command.address))
+    case "C_POP":
+        in.checkWrite(command.segment, command.index)
+        in.store(command.segment, command.index, command.address, in.pop())
+    case "C_MOVE":
+        value := in.load(command.segment, command.index, command.address)
+        in.checkWrite(command.destSegment, command.destIndex)
+        in.store(command.destSegment, command.destIndex, command.destAddress, value)
+    case "C_ARITHMETIC":
+        if err := in.arithmetic(command.segment); err != nil {
+            return fmt.Errorf("Step: %v in %s", err, in.CurrentFunction())
+        }
+    case "C_LABEL":
+    case "C_GOTO":
+        if command.target == in.pc-1 {
+            in.halted = true
+        }
+        next = command.target
+    case "C_IF":
+        if in.pop() != 0 {
+            next = command.target
+        }
+    case "C_IF_NOT":
+        if in.pop() != -1 {
+            next = command.target
+        }
+    case "C_FUNCTION":
+        if command.name == haltFunction {
+            in.halted = true
+        }
+        for i := 0; i < command.index; i++ {
+            in.push(0)
+        }
+    case "C_CALL":
+        if in.heap != nil {
+            sp := int(uint16(in.RAM[0]) & 0x7fff)
+            in.checkCall(command.name, in.RAM[max(sp-command.index, 0):sp])
+        }
+        if function, ok := in.natives[command.name]; ok {
+            if err := in.callNative(command.name, function, command.index); err != nil {
+                return fmt.Errorf("Step: %v in %s", err, in.CurrentFunction())
+            }
+            in.checkReturn(command.name, in.read(in.RAM[0]-1))
+            break
+        }
+        if command.target == -1 {
+            return fmt.Errorf("Step: function %s called from %s is not defined", command.name, in.CurrentFunction())
+        }
+        in.pushFrame(int16(uint16(next)), command.index)
+        next = command.target
+    case "C_RETURN":
+        in.checkReturn(in.CurrentFunction(), in.read(in.RAM[0]-1))
+        frame := in.RAM[1]
+        returnAddress := in.read(frame - 5)
+        in.write(in.RAM[2], in.pop())
+        in.RAM[0] = in.RAM[2] + 1
+        in.RAM[4] = in.read(frame - 1)
+        in.RAM[3] = in.read(frame - 2)
+        in.RAM[2] = in.read(frame - 3)
+        in.RAM[1] = in.read(frame - 4)
+        switch returnAddress {
+        case returnSentinel:
+            in.halted = true
+            next = returnSentinel
+        case nestedReturn:
+            in.callers = in.callers[:len(in.callers)-1]
+            next = nestedReturn
+        default:
+            next = int(uint16(returnAddress))
+        }
+    default:
+        return fmt.Errorf("Step: unknown command %s", in.lines[in.pc])
+    }
+
+    in.pc = next
+    return nil
+}
+
+func (in *Interpreter) pushFrame(returnAddress int16, numArgs int) {
+    in.push(returnAddress)
+    for pointer := 1; pointer <= 4; pointer++ {
+        in.push(in.RAM[pointer])
+    }
+    in.RAM[2] = in.RAM[0] - 5 - int16(numArgs)
+    in.RAM[1] = in.RAM[0]
+}
+
+func (in *Interpreter) read(address int16) int16 {
+    return in.RAM[uint16(address)&0x7fff]
+}
+
+func (in *Interpreter) write(address int16, value int16) {
+    in.RAM[uint16(address)&0x7fff] = value
+}
+
+func (in *Interpreter) push(value int16) {
+    in.write(in.RAM[0], value)
+    in.RAM[0]++
+}
+
+func (in *Interpreter) pop() int16 {
+    in.RAM[0]--
+    return in.read(in.RAM[0])
+}
+
+func (in *Interpreter) segmentAddress(segment string, index int, static int) int16 {
+    switch segment {
+    case "local":
+        return in.RAM[1] + int16(index)
+    case "argument":
+        return in.RAM[2] + int16(index)
+    case "this":
+        return in.RAM[3] + int16(index)
+    case "that":
+        return in.RAM[4] + int16(index)
+    case "temp":
+        return int16(5 + index)
+    case "pointer":
+        if index != 0 {
+            return 4
+        }
+        return 3
+    case "static":
+        return int16(static)
+    }
+    return -1
+}
+
+func (in *Interpreter) load(segment string, index int, static int) int16 {
+    if segment == "constant" {
+        return int16(index)
+    }
+    return in.read(in.segmentAddress(segment, index, static))
+}
+
+func (in *Interpreter) store(segment string, index int, static int, value int16) {
+    in.write(in.segmentAddress(segment, index, static), value)
+}
+
+func (in *Interpreter) arithmetic(command string) error {
+    switch command {
+    case "neg":
+        in.push(-in.pop())
+        return nil
+    case "not":
+        in.push(^in.pop())
+        return nil
+    }
+
+    y := in.pop()
+    x := in.pop()
+    result, ok := evaluateArithmetic(command, x, y)
+    if !ok {
+        return fmt.Errorf("unknown command %s", command)
+    }
+    in.push(result)
+    return nil
+}
+
+func (in *Interpreter) functionAt(pc int) string {
+    if pc < 0 || pc >= len(in.owners) || in.owners[pc] == -1 {
+        return "(top level)"
+    }
+    return in.commands[in.owners[pc]].name
+}
+
+func (in *Interpreter) CurrentFunction() string {
+    return in.functionAt(in.pc)
+}
+
+func (in *Interpreter) Halted() bool {
+    return in.halted
+}
+
+func (in *Interpreter) Steps() int {
+    return in.steps
+}
+
+func (in *Interpreter) Stack() []int16 {
+    sp := int(in.RAM[0])
+    if sp < stackBase || sp > len(in.RAM) {
+        return nil
+    }
+    return append([]int16{}, in.RAM[stackBase:sp]...)
+}
+
+func (in *Interpreter) Segment(segment string, index int) int16 {
+    return in.load(segment, index, -1)
+}
+
+func (in *Interpreter) Static(file string, index int) (int16, bool) {
+    address, ok := in.statics[file+"."+strconv.Itoa(index)]
+    if !ok {
+        return 0, false
+    }
+    return in.RAM[address], true
+}
+
+func (in *Interpreter) Heap() []int16 {
+    return append([]int16{}, in.RAM[heapBase:screenBase]...)
+}
+
+func (in *Interpreter) printState(ranges string) error {
+    fmt.Printf("Halted in %s after %d steps\n", in.CurrentFunction(), in.steps)
+    fmt.Printf("SP=%d LCL=%d ARG=%d THIS=%d THAT=%d\n", in.RAM[0], in.RAM[1], in.RAM[2], in.RAM[3], in.RAM[4])
+    fmt.Printf("stack: %v\n", in.Stack())
+    fmt.Printf("temp: %v\n", in.RAM[5:13])
+
+    names := make([]string, 0, len(in.statics))
+    for name := range in.statics {
+        names = append(names, name)
+    }
+    sort.Slice(names, func(i, j int) bool {
+        return in.statics[names[i]] < in.statics[names[j]]
+    })
+    for _, name := range names {
+        fmt.Printf("static %s = %d\n", name, in.RAM[in.statics[name]])
+    }
+
+    if ranges == "" {
+        return nil
+    }
+    for _, part := range strings.Split(ranges, ",") {
+        bounds := strings.SplitN(part, "-", 2)
+        from, err := strconv.Atoi(bounds[0])
+        if err != nil {
+            return fmt.Errorf("printState: invalid RAM range %s", part)
+        }
+        to := from
+        if len(bounds) == 2 {
+            if to, err = strconv.Atoi(bounds[1]); err != nil {
+                return fmt.Errorf("printState: invalid RAM range %s", part)
+            }
+        }
+        for address := from; address <= to && address < len(in.RAM); address++ {
+            fmt.Printf("RAM[%d] = %d\n", address, in.RAM[address])
+        }
+    }
+    return nil
+}
+
+func runInterpreter(directory string, options interpreterOptions) error {
+    in, err := NewInterpreter(directory)
+    if err != nil {
+        return err
+    }
+    in.MaxSteps = options.steps
+    for _, class := range options.native {
+        if err := in.UseNativeClass(class); err != nil {
+            return err
+        }
+    }
+
+    if options.checkHeap {
+        in.CheckHeap()
+    }
+    if options.guards {
+        in.EnableGuards()
+    }
+
+    runErr := in.Run(options.entry)
+    if in.heap != nil {
+        // Keep the JSON dump on stdout parseable.
+        report := io.Writer(os.Stdout)
+        if options.dump == "json" {
+            report = os.Stderr
+        }
+        defer in.printHeapReport(report)
+    }
+    if options.dump == "json" {
+        if err := in.printDump(options.dump, runErr); err != nil {
+            return err
+        }
+        return runErr
+    }
+    if err := in.printState(options.ranges); err != nil {
+        return err
+    }
+    if options.dump != "" {
+        if err := in.printDump(options.dump, runErr); err != nil {
+            return err
+        }
+    }
+    return runErr
+}
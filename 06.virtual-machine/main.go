@@ -1,21 +1,36 @@
-package main
+package vmtranslator
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 )
 
-func NewVMTranslator(file string, isDirectory bool) *VMTranslator {
+// NewVMTranslator reads a single .vm or .vmb file, or, when isDirectory is
+// set, every .vm file in the directory path. A directory's output is named
+// after Sys, the class that holds the program's entry point.
+func NewVMTranslator(path string, isDirectory bool) *VMTranslator {
+    file := path
+    directory := ""
+    if isDirectory {
+        file = filepath.Join(path, "Sys")
+        directory = path
+    }
     return &VMTranslator{
-        fileName:      file,
-        isDirectory:   isDirectory,
-        parsedContent: make([]string, 0),
-        code:         make([]string, 0),
-        returnCounter: 0,
+        fileName:        file,
+        directory:       directory,
+        isDirectory:     isDirectory,
+        parsedContent:   make([]string, 0),
+        code:            make([]string, 0),
+        returnCounter:   0,
+        entry:           "Sys.init",
+        inlineThreshold: 16,
+        jobs:            runtime.NumCPU(),
     }
 }
 
@@ -31,11 +46,15 @@ func (vm *VMTranslator) readFile(filePath string) error {
     
     for scanner.Scan() {
         line := scanner.Text()
-        if strings.Contains(line, "static") {
+        code := line
+        if commentIdx := strings.Index(code, "//"); commentIdx != -1 {
+            code = code[:commentIdx]
+        }
+        if hasStaticOperand(strings.Fields(code)) {
             fileName := filepath.Base(filePath)
             fileExt := filepath.Ext(fileName)
             baseName := strings.TrimSuffix(fileName, fileExt)
-            line = line + " " + baseName
+            line = strings.TrimSpace(code) + " " + baseName
         }
         fileContent += line + "\n"
     }
@@ -45,6 +64,9 @@ func (vm *VMTranslator) readFile(filePath string) error {
 }
 
 func (vm *VMTranslator) parse() error {
+    if !vm.isDirectory && filepath.Ext(vm.fileName) == ".vmb" {
+        return vm.readBytecode(vm.fileName)
+    }
     if !vm.isDirectory {
         if err := vm.readFile(vm.fileName); err != nil {
             return err
@@ -56,18 +78,43 @@ func (vm *VMTranslator) parse() error {
     }
 
     lines := strings.Split(vm.file, "\n")
-    for _, line := range lines {
+    for i, line := range lines {
         line = strings.TrimSpace(line)
         if commentIdx := strings.Index(line, "//"); commentIdx != -1 {
             line = strings.TrimSpace(line[:commentIdx])
         }
         if line != "" {
+            if err := checkCommand(line); err != nil {
+                return fmt.Errorf("%s:%d: %v: %s", filepath.Base(vm.fileName), i+1, err, line)
+            }
             vm.parsedContent = append(vm.parsedContent, line)
         }
     }
     return nil
 }
 
+// hasStaticOperand reports whether a push, pop or move command names the
+// static segment, and so may carry the name of the file owning it.
+func hasStaticOperand(args []string) bool {
+    if len(args) < 2 {
+        return false
+    }
+    switch args[0] {
+    case "push", "pop":
+        return args[1] == "static"
+    case "move":
+        return args[1] == "static" || len(args) > 3 && args[3] == "static"
+    }
+    return false
+}
+
+// checkCommand rejects a command with an unknown name or segment, or with
+// missing, extra or malformed operands, using the checks of the bytecode encoder.
+func checkCommand(line string) error {
+    w := &bytecodeWriter{ids: make(map[string]int)}
+    return w.command(line)
+}
+
 func (vm *VMTranslator) writeFile() error {
     if err := vm.translate(); err != nil {
         return err
@@ -76,26 +123,44 @@ func (vm *VMTranslator) writeFile() error {
     fileName := strings.TrimSuffix(vm.fileName, filepath.Ext(vm.fileName))
     content := strings.Join(vm.code, "\n")
 
+    if vm.guards {
+        if err := os.WriteFile(fileName+".guards", []byte(vm.guardReport()), 0644); err != nil {
+            return err
+        }
+    }
     return os.WriteFile(fileName+".asm", []byte(content), 0644)
 }
 
+// Translate translates the .vm files of a directory into one Hack assembly
+// program that starts with the bootstrap code. The files must make up the
+// whole program: calls to functions that none of them define are errors.
+func Translate(directory string) (string, error) {
+    vm := NewVMTranslator(directory, true)
+    if err := vm.prepare(); err != nil {
+        return "", err
+    }
+    if err := checkCalls(vm.parsedContent, vm.entry); err != nil {
+        return "", err
+    }
+
+    vm.code = vm.generateUnits(vm.parsedContent)
+    vm.loadBootstrapCode()
+    return strings.Join(vm.code, "\n"), nil
+}
+
 func (vm *VMTranslator) parseDirectory(directory string) error {
     files, err := os.ReadDir(directory)
     if err != nil {
         return err
     }
 
+    var paths []string
     for _, file := range files {
         if filepath.Ext(file.Name()) == ".vm" {
-            if err := vm.readFile(filepath.Join(directory, file.Name())); err != nil {
-                return err
-            }
-            if err := vm.parse(); err != nil {
-                return err
-            }
+            paths = append(paths, filepath.Join(directory, file.Name()))
         }
     }
-    return nil
+    return vm.parseFiles(paths)
 }
 
 func (vm *VMTranslator) commandType(line string) string {
@@ -109,6 +174,10 @@ func (vm *VMTranslator) commandType(line string) string {
         return "C_GOTO"
     } else if strings.HasPrefix(line, "if-goto") {
         return "C_IF"
+    } else if strings.HasPrefix(line, "ifnot-goto") {
+        return "C_IF_NOT"
+    } else if strings.HasPrefix(line, "move") {
+        return "C_MOVE"
     } else if strings.HasPrefix(line, "function") {
         return "C_FUNCTION"
     } else if strings.HasPrefix(line, "call") {
@@ -435,6 +504,97 @@ func (vm *VMTranslator) writeIf(line string) string {
 		D;JNE`, line, label)
 }
 
+func (vm *VMTranslator) writeIfNot(line string) string {
+    label := strings.Fields(line)[1]
+    return fmt.Sprintf(`// %s
+		@SP
+		AM=M-1
+		D=M+1
+		@%s
+		D;JNE`, line, label)
+}
+
+func (vm *VMTranslator) segmentAddress(segment, index, fileName string) (string, bool) {
+    switch segment {
+    case "local":
+        return "LCL", false
+    case "argument":
+        return "ARG", false
+    case "this":
+        return "THIS", false
+    case "that":
+        return "THAT", false
+    case "temp":
+        return strconv.Itoa(5 + parseInt(index)), true
+    case "pointer":
+        if parseInt(index) != 0 {
+            return "THAT", true
+        }
+        return "THIS", true
+    case "static":
+        return fmt.Sprintf("%s.%s", fileName, index), true
+    }
+    return "", false
+}
+
+func (vm *VMTranslator) writeMove(line string) string {
+    args := strings.Fields(line)
+    source, sourceIndex := args[1], args[2]
+    target, targetIndex := args[3], args[4]
+    var fileName string
+    if len(args) > 5 {
+        fileName = args[5]
+    }
+
+    var load string
+    if source == "constant" {
+        load = fmt.Sprintf(`
+			@%s
+			D=A`, sourceIndex)
+    } else if address, direct := vm.segmentAddress(source, sourceIndex, fileName); direct {
+        load = fmt.Sprintf(`
+			@%s
+			D=M`, address)
+    } else {
+        load = fmt.Sprintf(`
+			@%s
+			D=M
+			@%s
+			A=D+A
+			D=M`, address, sourceIndex)
+    }
+
+    address, direct := vm.segmentAddress(target, targetIndex, fileName)
+    if direct {
+        return fmt.Sprintf(`// %s%s
+			@%s
+			M=D`, line, load, address)
+    }
+
+    if offset := parseInt(targetIndex); offset <= 2 {
+        store := fmt.Sprintf(`
+			@%s
+			A=M`, address)
+        for i := 0; i < offset; i++ {
+            store += `
+			A=A+1`
+        }
+        return fmt.Sprintf(`// %s%s%s
+			M=D`, line, load, store)
+    }
+
+    return fmt.Sprintf(`// %s
+			@%s
+			D=M
+			@%s
+			D=D+A
+			@R13
+			M=D%s
+			@R13
+			A=M
+			M=D`, line, address, targetIndex, load)
+}
+
 func (vm *VMTranslator) writeFunction(line string) string {
     args := strings.Fields(line)
     functionName := args[1]
@@ -557,9 +717,99 @@ func (vm *VMTranslator) writeCall(line string) string {
 		(%s)`, line, returnAddress, numArgs, functionName, returnAddress)
 }
 
+// writeTailCall reuses the current frame for a call that is immediately
+// returned: the caller's saved frame is parked above the stack, the new
+// arguments are moved down to ARG and the frame is rebuilt right after them.
+func (vm *VMTranslator) writeTailCall(line string) string {
+    args := strings.Fields(line)
+    functionName := args[1]
+    numArgs := parseInt(args[2])
+
+    code := fmt.Sprintf(`// %s (tail call)`, line)
+
+    for i := 0; i < 5; i++ {
+        code += fmt.Sprintf(`
+		@LCL
+		D=M
+		@%d
+		A=D-A
+		D=M
+		@SP
+		A=M`, 5-i)
+        for j := 0; j < i; j++ {
+            code += `
+		A=A+1`
+        }
+        code += `
+		M=D`
+    }
+
+    code += `
+		@ARG
+		D=M
+		@R13
+		M=D`
+    for i := 0; i < numArgs; i++ {
+        code += fmt.Sprintf(`
+		@SP
+		D=M
+		@%d
+		A=D-A
+		D=M
+		@R13
+		A=M
+		M=D
+		@R13
+		M=M+1`, numArgs-i)
+    }
+
+    for i := 0; i < 5; i++ {
+        code += fmt.Sprintf(`
+		@SP
+		D=M
+		@%d
+		A=D+A
+		D=M
+		@R13
+		A=M
+		M=D
+		@R13
+		M=M+1`, i)
+    }
+
+    return code + fmt.Sprintf(`
+		@R13
+		D=M
+		@LCL
+		M=D
+		@SP
+		M=D
+		@%s
+		0;JMP`, functionName)
+}
+
+func (vm *VMTranslator) isTailCall(lines []string, i int) bool {
+    return vm.tailCalls && vm.commandType(lines[i]) == "C_CALL" &&
+        i+1 < len(lines) && vm.commandType(lines[i+1]) == "C_RETURN"
+}
+
 func (vm *VMTranslator) translate() error {
+    if err := vm.prepare(); err != nil {
+        return err
+    }
+
+    vm.code = append(vm.code, vm.generateUnits(vm.parsedContent)...)
+    if vm.guards {
+        vm.code = append(vm.code, vm.writeGuardHandlers()...)
+    }
+    return nil
+}
+
+// prepare parses the input and runs the VM-level passes that every backend
+// shares.
+func (vm *VMTranslator) prepare() error {
     if vm.isDirectory {
-        if err := vm.parseDirectory(filepath.Dir(vm.fileName)); err != nil {
+        if err := vm.parseDirectory(vm.directory); err != nil {
             return err
         }
     } else {
@@ -572,45 +822,104 @@ func (vm *VMTranslator) translate() error {
         return fmt.Errorf("translate: No content to translate")
     }
 
-    for i, line := range vm.parsedContent {
+    if vm.guards && vm.cacheTop {
+        return fmt.Errorf("translate: guards need the stack pointer in memory and cannot be combined with -cache-top")
+    }
+
+    if vm.inline {
+        vm.parsedContent = vm.inlineFunctions(vm.parsedContent)
+    }
+
+    if vm.removeDead {
+        lines, err := vm.removeDeadFunctions(vm.parsedContent)
+        if err != nil {
+            return err
+        }
+        vm.parsedContent = lines
+    }
+
+    if vm.optimize {
+        vm.parsedContent = vm.optimizeCommands(vm.parsedContent)
+    }
+    return nil
+}
+
+// generate translates lines[from:to]. Labels made from a command's position
+// use its index in the whole program, so the ranges can be generated
+// separately and joined.
+func (vm *VMTranslator) generate(lines []string, from, to int) []string {
+    var code []string
+
+    numLocals := make(map[string]int)
+    if vm.guards {
+        for _, line := range lines {
+            if args := strings.Fields(line); args[0] == "function" {
+                numLocals[args[1]] = parseInt(args[2])
+            }
+        }
+    }
+    var function string
+
+    for i := from; i < to; i++ {
+        line := lines[i]
         cmdType := vm.commandType(line)
-        var code string
+        var command string
+
+        if cmdType == "C_FUNCTION" {
+            function = strings.Fields(line)[1]
+        }
+        if vm.guards {
+            if guard := vm.writeGuards(line, function, numLocals[function], numLocals); guard != "" {
+                code = append(code, guard)
+            }
+        }
 
         switch cmdType {
         case "C_PUSH":
-            code = vm.writePush(line)
+            command = vm.writePush(line)
         case "C_POP":
-            code = vm.writePop(line)
+            command = vm.writePop(line)
         case "C_ARITHMETIC":
-            code = vm.writeArithmetic(line, i)
+            command = vm.writeArithmetic(line, i)
         case "C_LABEL":
-            code = vm.writeLabel(line)
+            command = vm.writeLabel(line)
         case "C_GOTO":
-            code = vm.writeGoto(line)
+            command = vm.writeGoto(line)
         case "C_IF":
-            code = vm.writeIf(line)
+            command = vm.writeIf(line)
+        case "C_IF_NOT":
+            command = vm.writeIfNot(line)
+        case "C_MOVE":
+            command = vm.writeMove(line)
         case "C_FUNCTION":
-            code = vm.writeFunction(line)
+            command = vm.writeFunction(line)
         case "C_CALL":
-            code = vm.writeCall(line)
+            if vm.isTailCall(lines, i) {
+                command = vm.writeTailCall(line)
+            } else {
+                command = vm.writeCall(line)
+            }
         case "C_RETURN":
-            code = vm.writeReturn(line)
+            if i > 0 && vm.isTailCall(lines, i-1) {
+                continue
+            }
+            command = vm.writeReturn(line)
         default:
             fmt.Printf("Unknown command: %s\n", line)
             continue
         }
-        vm.code = append(vm.code, code)
+        code = append(code, command)
     }
-    return nil
+    return code
 }
 
 func (vm *VMTranslator) loadBootstrapCode() {
-    bootstrapCode := `// Bootstrap code
+    bootstrapCode := fmt.Sprintf(`// Bootstrap code
 		@256
 		D=A
 		@SP
 		M=D
-		@Sys.init$RETURN0
+		@%[1]s$RETURN0
 		D=A
 		@SP
 		A=M
@@ -657,9 +966,9 @@ func (vm *VMTranslator) loadBootstrapCode() {
 		D=M
 		@LCL
 		M=D	// LCL = SP
-		@Sys.init
+		@%[1]s
 		0;JMP
-		(Sys.init$RETURN0)`
+		(%[1]s$RETURN0)`, vm.entry)
 
     vm.code = append([]string{bootstrapCode}, vm.code...)
 }
@@ -673,10 +982,82 @@ func parseInt(s string) int {
 }
 
 
-func main() {
-    vm := NewVMTranslator("Sys", true)
-    vm.loadBootstrapCode()
-    if err := vm.writeFile(); err != nil {
+// Main runs the VM translator command line; see cmd/vm-translator.
+func Main() {
+    optimize := flag.Bool("optimize", false, "fold constants, fuse push/pop pairs and remove dead code before emitting assembly")
+    cacheTop := flag.Bool("cache-top", false, "keep the top of the stack in the D register across straight-line code")
+    removeDead := flag.Bool("remove-dead", false, "drop functions that are never called from the entry point")
+    entry := flag.String("entry", "Sys.init", "function called by the bootstrap code")
+    keep := flag.String("keep", "", "comma separated functions to keep even if they are never called")
+    inline := flag.Bool("inline", false, "replace calls to small non-recursive functions with their bodies")
+    inlineThreshold := flag.Int("inline-threshold", 16, "largest function body, in VM commands, that is inlined")
+    tailCalls := flag.Bool("tail-calls", false, "reuse the caller's frame for a call that is immediately returned")
+    guards := flag.Bool("guards", false, "check the stack and this/that accesses at run time; translated code halts with the failing site number in R15, the interpreter stops with an error")
+    target := flag.String("target", "asm", "output to write: \"asm\" for Hack assembly, \"c\" for a C program, \"go\" for a Go program, \"elf\" for a Linux x86-64 executable, \"vmb\" for binary bytecode or \"vm\" for one .vm file per class in a directory named after the program with -vm appended")
+    jobs := flag.Int("jobs", runtime.NumCPU(), "number of files parsed and translated at the same time; the output does not depend on it")
+    run := flag.Bool("run", false, "interpret the .vm files in the directory, or the .vm or .vmb file, given as argument instead of translating them")
+    steps := flag.Int("steps", 0, "stop the interpreter after this many VM commands (0 means no limit)")
+    ram := flag.String("ram", "", "comma separated RAM ranges to print after the interpreter stops, e.g. 16-20,2048")
+    dump := flag.String("dump", "", "print the call stack and heap after the interpreter stops, as \"text\" or \"json\"")
+    checkHeap := flag.Bool("check-heap", false, "track Memory.alloc and Memory.deAlloc in the interpreter and report leaks, bad frees and writes outside allocated blocks")
+    native := flag.String("native", "", "comma separated OS classes the interpreter runs in Go instead of VM code, or \"all\"")
+    flag.Parse()
+
+    if *run {
+        directory := "."
+        if flag.NArg() > 0 {
+            directory = flag.Arg(0)
+        }
+        var classes []string
+        if *native == "all" {
+            classes = NativeClassNames()
+        } else if *native != "" {
+            classes = strings.Split(*native, ",")
+        }
+        options := interpreterOptions{
+            entry:     *entry,
+            steps:     *steps,
+            ranges:    *ram,
+            native:    classes,
+            dump:      *dump,
+            checkHeap: *checkHeap,
+            guards:    *guards,
+        }
+        if err := runInterpreter(directory, options); err != nil {
+            if *dump != "json" {
+                fmt.Printf("Error: %v\n", err)
+            }
+            os.Exit(1)
+        }
+        return
+    }
+
+    vm := NewVMTranslator(".", true)
+    if flag.NArg() > 0 {
+        info, err := os.Stat(flag.Arg(0))
+        if err != nil {
+            fmt.Printf("Error: %v\n", err)
+            os.Exit(1)
+        }
+        if info.IsDir() {
+            vm = NewVMTranslator(flag.Arg(0), true)
+        } else {
+            vm = NewVMTranslator(flag.Arg(0), false)
+        }
+    }
+    vm.optimize = *optimize
+    vm.cacheTop = *cacheTop
+    vm.removeDead = *removeDead
+    vm.entry = *entry
+    vm.inline = *inline
+    vm.inlineThreshold = *inlineThreshold
+    vm.tailCalls = *tailCalls
+    vm.guards = *guards
+    vm.jobs = *jobs
+    if *keep != "" {
+        vm.keep = strings.Split(*keep, ",")
+    }
+    if err := vm.writeTarget(*target); err != nil {
         fmt.Printf("Error: %v\n", err)
         os.Exit(1)
     }
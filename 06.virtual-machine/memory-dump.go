@@ -0,0 +1,204 @@
+package vmtranslator
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const maxFrames = 4096
+
+func (in *Interpreter) Dump(runErr error) memoryDump {
+    dump := memoryDump{
+        Function: in.CurrentFunction(),
+        Steps:    in.steps,
+        SP:       in.RAM[0],
+    }
+    if runErr != nil {
+        dump.Error = runErr.Error()
+    }
+    dump.Frames, dump.Problems = in.Frames()
+
+    heap, freeBlocks, problems := in.HeapBlocks()
+    dump.Heap = heap
+    dump.FreeBlocks = freeBlocks
+    dump.Problems = append(dump.Problems, problems...)
+    return dump
+}
+
+// Frames walks the saved LCL/ARG chain from the innermost frame outwards.
+// Each frame owns its arguments, the five saved words, its locals and the
+// operand stack up to the arguments of the frame above it.
+func (in *Interpreter) Frames() ([]stackFrame, []string) {
+    var frames []stackFrame
+    var problems []string
+
+    sp, lcl, arg := in.RAM[0], in.RAM[1], in.RAM[2]
+    pc := in.pc
+    caller := len(in.callers) - 1
+    if pc < 0 {
+        return nil, nil
+    }
+
+    for len(frames) < maxFrames {
+        name := in.functionAt(pc)
+        if lcl < stackBase+5 || lcl > sp || arg < stackBase || arg > lcl-5 {
+            problems = append(problems, fmt.Sprintf("frame of %s is corrupt: SP=%d LCL=%d ARG=%d", name, sp, lcl, arg))
+            break
+        }
+
+        numLocals := int16(0)
+        if start, ok := in.functions[name]; ok {
+            numLocals = min(int16(in.commands[start].index), sp-lcl)
+        }
+
+        frame := stackFrame{
+            Function:      name,
+            Command:       pc,
+            ReturnAddress: returnAddress(in.read(lcl - 5)),
+            SavedLCL:      in.read(lcl - 4),
+            SavedARG:      in.read(lcl - 3),
+            SavedTHIS:     in.read(lcl - 2),
+            SavedTHAT:     in.read(lcl - 1),
+            Arguments:     append([]int16{}, in.RAM[arg:lcl-5]...),
+            Locals:        append([]int16{}, in.RAM[lcl:lcl+numLocals]...),
+            Stack:         append([]int16{}, in.RAM[lcl+numLocals:sp]...),
+        }
+
+        done := false
+        switch frame.ReturnAddress {
+        case returnSentinel:
+            frame.ReturnTo = "(bootstrap)"
+            done = true
+        case nestedReturn:
+            if caller < 0 {
+                problems = append(problems, fmt.Sprintf("frame of %s returns to a native caller that is not running", name))
+                done = true
+                break
+            }
+            pc = in.callers[caller]
+            caller--
+            frame.ReturnTo = in.functionAt(pc) + " (native call)"
+        default:
+            pc = frame.ReturnAddress - 1
+            frame.ReturnTo = in.functionAt(pc)
+        }
+
+        frames = append(frames, frame)
+        if done {
+            break
+        }
+        sp, lcl, arg = arg, frame.SavedLCL, frame.SavedARG
+    }
+    return frames, problems
+}
+
+// returnAddress reads a saved return address as the unsigned command number
+// it stands for, keeping the two sentinels negative.
+func returnAddress(word int16) int {
+    if word == returnSentinel || word == nestedReturn {
+        return int(word)
+    }
+    return int(uint16(word))
+}
+
+func (in *Interpreter) commandAt(pc int) string {
+    if pc < 0 || pc >= len(in.lines) {
+        return fmt.Sprintf("command %d", pc)
+    }
+    return fmt.Sprintf("command %d (%s)", pc, in.lines[pc])
+}
+
+func (in *Interpreter) freeListHead() (int16, bool) {
+    if _, ok := in.natives["Memory.alloc"]; ok {
+        return in.native.freeBlocks, true
+    }
+    return in.Static("Memory", 0)
+}
+
+// HeapBlocks walks the heap block by block from heapBase using the
+// [length, next] headers of Memory.jack and marks the blocks that are on
+// the free list.
+func (in *Interpreter) HeapBlocks() ([]heapBlock, int16, []string) {
+    head, ok := in.freeListHead()
+    if !ok {
+        return nil, 0, []string{"no Memory class, heap not walked"}
+    }
+    if head == 0 {
+        return nil, 0, []string{"Memory.init has not run, heap not walked"}
+    }
+
+    var problems []string
+    free := make(map[int16]bool)
+    for block := head; block != 0; block = in.read(block + 1) {
+        if block < heapBase || block >= screenBase {
+            problems = append(problems, fmt.Sprintf("free list points outside the heap at %d", block))
+            break
+        }
+        if free[block] {
+            problems = append(problems, fmt.Sprintf("free list loops back to %d", block))
+            break
+        }
+        free[block] = true
+    }
+
+    var blocks []heapBlock
+    for address := int(heapBase); address+1 < screenBase; {
+        length := in.RAM[address]
+        if length < 0 {
+            problems = append(problems, fmt.Sprintf("block at %d has negative length %d", address, length))
+            break
+        }
+        blocks = append(blocks, heapBlock{
+            Address: int16(address),
+            Length:  length,
+            Free:    free[int16(address)],
+        })
+        delete(free, int16(address))
+        address += 2 + int(length)
+    }
+    for block := range free {
+        problems = append(problems, fmt.Sprintf("free block %d is not on a block boundary", block))
+    }
+    return blocks, head, problems
+}
+
+func (in *Interpreter) printDump(format string, runErr error) error {
+    dump := in.Dump(runErr)
+
+    switch format {
+    case "json":
+        data, err := json.MarshalIndent(dump, "", "  ")
+        if err != nil {
+            return fmt.Errorf("printDump: %v", err)
+        }
+        fmt.Println(string(data))
+        return nil
+    case "text":
+    default:
+        return fmt.Errorf("printDump: unknown format %s", format)
+    }
+
+    fmt.Printf("Call stack (%d frames, innermost first):\n", len(dump.Frames))
+    for i, frame := range dump.Frames {
+        fmt.Printf("#%d %s at %s\n", i, frame.Function, in.commandAt(frame.Command))
+        fmt.Printf("    return address %d -> %s\n", frame.ReturnAddress, frame.ReturnTo)
+        fmt.Printf("    saved LCL=%d ARG=%d THIS=%d THAT=%d\n", frame.SavedLCL, frame.SavedARG, frame.SavedTHIS, frame.SavedTHAT)
+        fmt.Printf("    arguments: %v\n", frame.Arguments)
+        fmt.Printf("    locals: %v\n", frame.Locals)
+        fmt.Printf("    stack: %v\n", frame.Stack)
+    }
+
+    fmt.Printf("Heap (free list starts at %d):\n", dump.FreeBlocks)
+    for _, block := range dump.Heap {
+        state := "allocated"
+        if block.Free {
+            state = "free"
+        }
+        fmt.Printf("  %5d-%5d  length %5d  %s\n", block.Address, int(block.Address)+1+int(block.Length), block.Length, state)
+    }
+
+    for _, problem := range dump.Problems {
+        fmt.Printf("Problem: %s\n", problem)
+    }
+    return nil
+}
@@ -0,0 +1,653 @@
+package vmtranslator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+var errHalted = errors.New("program halted")
+
+var nativeClasses = map[string]map[string]NativeFunction{
+    "Math": {
+        "init":     nativeReturn,
+        "abs":      mathAbs,
+        "multiply": mathMultiply,
+        "divide":   mathDivide,
+        "min":      mathMin,
+        "max":      mathMax,
+        "sqrt":     mathSqrt,
+    },
+    "Memory": {
+        "init":    memoryInit,
+        "peek":    memoryPeek,
+        "poke":    memoryPoke,
+        "alloc":   memoryAlloc,
+        "deAlloc": memoryDeAlloc,
+    },
+    "Array": {
+        "new":     arrayNew,
+        "dispose": arrayDispose,
+    },
+    "String": {
+        "new":           stringNew,
+        "dispose":       stringDispose,
+        "length":        stringLength,
+        "charAt":        stringCharAt,
+        "setCharAt":     stringSetCharAt,
+        "appendChar":    stringAppendChar,
+        "eraseLastChar": stringEraseLastChar,
+        "intValue":      stringIntValue,
+        "setInt":        stringSetInt,
+        "newLine":       nativeConstant(128),
+        "backSpace":     nativeConstant(129),
+        "doubleQuote":   nativeConstant(34),
+    },
+    "Output": {
+        "init":        outputInit,
+        "moveCursor":  outputMoveCursor,
+        "printChar":   outputPrintChar,
+        "printString": outputPrintString,
+        "printInt":    outputPrintInt,
+        "println":     outputPrintln,
+        "backSpace":   outputBackSpace,
+    },
+    "Screen": {
+        "init":          screenInit,
+        "clearScreen":   screenClear,
+        "setColor":      screenSetColor,
+        "drawPixel":     screenDrawPixel,
+        "drawLine":      screenDrawLine,
+        "drawRectangle": screenDrawRectangle,
+        "drawCircle":    screenDrawCircle,
+    },
+    "Sys": {
+        "halt":  sysHalt,
+        "error": sysError,
+        "wait":  nativeReturn,
+    },
+}
+
+func (in *Interpreter) RegisterNative(name string, function NativeFunction) {
+    in.natives[name] = function
+}
+
+func (in *Interpreter) UseNativeClass(class string) error {
+    functions, ok := nativeClasses[class]
+    if !ok {
+        return fmt.Errorf("UseNativeClass: no native implementation of %s", class)
+    }
+    for name, function := range functions {
+        in.RegisterNative(class+"."+name, function)
+    }
+    if class == "Memory" {
+        memoryInit(in, nil)
+    }
+    if class == "Screen" {
+        in.native.color = true
+    }
+    return nil
+}
+
+func (in *Interpreter) UseJackClass(class string) {
+    for name := range nativeClasses[class] {
+        delete(in.natives, class+"."+name)
+    }
+}
+
+func NativeClassNames() []string {
+    names := make([]string, 0, len(nativeClasses))
+    for name := range nativeClasses {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Call runs a function to completion from Go code and returns its result.
+// VM functions get a real frame whose return address marks the nested call.
+func (in *Interpreter) Call(name string, args ...int16) (int16, error) {
+    in.checkCall(name, args)
+    if function, ok := in.natives[name]; ok {
+        result, err := function(in, args)
+        if err == nil {
+            in.checkReturn(name, result)
+        }
+        return result, err
+    }
+    target, ok := in.functions[name]
+    if !ok {
+        return 0, fmt.Errorf("Call: function %s is not defined", name)
+    }
+
+    for _, arg := range args {
+        in.push(arg)
+    }
+    in.pushFrame(nestedReturn, len(args))
+
+    caller := in.pc
+    in.callers = append(in.callers, caller)
+    depth := len(in.callers)
+    in.pc = target
+    for len(in.callers) >= depth {
+        if in.halted {
+            return 0, errHalted
+        }
+        if in.MaxSteps > 0 && in.steps >= in.MaxSteps {
+            return 0, fmt.Errorf("Call: step limit of %d reached in %s", in.MaxSteps, in.CurrentFunction())
+        }
+        if err := in.Step(); err != nil {
+            return 0, err
+        }
+    }
+    in.pc = caller
+    return in.pop(), nil
+}
+
+func (in *Interpreter) callNative(name string, function NativeFunction, numArgs int) error {
+    in.RAM[0] -= int16(numArgs)
+    sp := int(uint16(in.RAM[0]) & 0x7fff)
+    args := append([]int16{}, in.RAM[sp:sp+numArgs]...)
+
+    result, err := function(in, args)
+    if errors.Is(err, errHalted) {
+        in.halted = true
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("%s: %v", name, err)
+    }
+    in.push(result)
+    return nil
+}
+
+func nativeReturn(in *Interpreter, args []int16) (int16, error) {
+    return 0, nil
+}
+
+func nativeConstant(value int16) NativeFunction {
+    return func(in *Interpreter, args []int16) (int16, error) {
+        return value, nil
+    }
+}
+
+func mathAbs(in *Interpreter, args []int16) (int16, error) {
+    if args[0] < 0 {
+        return -args[0], nil
+    }
+    return args[0], nil
+}
+
+func mathMultiply(in *Interpreter, args []int16) (int16, error) {
+    return args[0] * args[1], nil
+}
+
+func mathDivide(in *Interpreter, args []int16) (int16, error) {
+    if args[1] == 0 {
+        return 0, fmt.Errorf("division by zero")
+    }
+    return args[0] / args[1], nil
+}
+
+func mathMin(in *Interpreter, args []int16) (int16, error) {
+    return min(args[0], args[1]), nil
+}
+
+func mathMax(in *Interpreter, args []int16) (int16, error) {
+    return max(args[0], args[1]), nil
+}
+
+func mathSqrt(in *Interpreter, args []int16) (int16, error) {
+    if args[0] < 0 {
+        return 0, fmt.Errorf("square root of negative number %d", args[0])
+    }
+    root := int16(0)
+    for (int32(root)+1)*(int32(root)+1) <= int32(args[0]) {
+        root++
+    }
+    return root, nil
+}
+
+// The native heap follows Memory.jack step by step, so both leave the same
+// heap behind: each block starts with its length and the next free block,
+// Memory.init starts with a single free block of 14335 words, alloc takes
+// the first block that fits, always splits off the rest of it and returns
+// block+2, or -1 when no block fits, and deAlloc puts the block back at the
+// head of the free list. Only sizes below 1 are rejected, where Memory.jack
+// would hand out overlapping blocks.
+func memoryInit(in *Interpreter, args []int16) (int16, error) {
+    in.native.freeBlocks = heapBase
+    in.RAM[heapBase] = 14335
+    in.RAM[heapBase+1] = 0
+    return 0, nil
+}
+
+func memoryPeek(in *Interpreter, args []int16) (int16, error) {
+    return in.read(args[0]), nil
+}
+
+func memoryPoke(in *Interpreter, args []int16) (int16, error) {
+    in.write(args[0], args[1])
+    return 0, nil
+}
+
+func memoryAlloc(in *Interpreter, args []int16) (int16, error) {
+    size := args[0]
+    if size <= 0 {
+        return 0, fmt.Errorf("invalid allocation size %d", size)
+    }
+
+    previous := int16(0)
+    block := in.native.freeBlocks
+    for in.read(block) < size {
+        previous = block
+        block = in.read(block + 1)
+        if block == 0 {
+            return -1, nil
+        }
+    }
+
+    rest := block + 2 + size
+    in.write(rest, in.read(block)-size-2)
+    in.write(rest+1, in.read(block+1))
+    in.write(block, size)
+    in.write(block+1, 0)
+
+    if previous == 0 {
+        in.native.freeBlocks = rest
+    } else {
+        in.write(previous+1, rest)
+    }
+    return block + 2, nil
+}
+
+func memoryDeAlloc(in *Interpreter, args []int16) (int16, error) {
+    block := args[0] - 2
+    in.write(block+1, in.native.freeBlocks)
+    in.native.freeBlocks = block
+    return 0, nil
+}
+
+func arrayNew(in *Interpreter, args []int16) (int16, error) {
+    return in.Call("Memory.alloc", args[0])
+}
+
+func arrayDispose(in *Interpreter, args []int16) (int16, error) {
+    return in.Call("Memory.deAlloc", args[0])
+}
+
+// Native strings keep the field layout of String.jack (len, maxLen, chars)
+// so they can be passed to Jack code and back.
+func stringNew(in *Interpreter, args []int16) (int16, error) {
+    maxLength := max(args[0], 1)
+    this, err := in.Call("Memory.alloc", 3)
+    if err != nil {
+        return 0, err
+    }
+    chars, err := in.Call("Array.new", maxLength)
+    if err != nil {
+        return 0, err
+    }
+    in.write(this, 0)
+    in.write(this+1, maxLength)
+    in.write(this+2, chars)
+    return this, nil
+}
+
+func stringDispose(in *Interpreter, args []int16) (int16, error) {
+    if _, err := in.Call("Array.dispose", in.read(args[0]+2)); err != nil {
+        return 0, err
+    }
+    return in.Call("Memory.deAlloc", args[0])
+}
+
+func stringLength(in *Interpreter, args []int16) (int16, error) {
+    return in.read(args[0]), nil
+}
+
+func stringCharAt(in *Interpreter, args []int16) (int16, error) {
+    return in.read(in.read(args[0]+2) + args[1]), nil
+}
+
+func stringSetCharAt(in *Interpreter, args []int16) (int16, error) {
+    in.write(in.read(args[0]+2)+args[1], args[2])
+    return 0, nil
+}
+
+func stringAppendChar(in *Interpreter, args []int16) (int16, error) {
+    this := args[0]
+    length := in.read(this)
+    if length < in.read(this+1) {
+        in.write(in.read(this+2)+length, args[1])
+        in.write(this, length+1)
+    }
+    return this, nil
+}
+
+func stringEraseLastChar(in *Interpreter, args []int16) (int16, error) {
+    if length := in.read(args[0]); length > 0 {
+        in.write(args[0], length-1)
+    }
+    return 0, nil
+}
+
+func stringIntValue(in *Interpreter, args []int16) (int16, error) {
+    this := args[0]
+    length := in.read(this)
+    chars := in.read(this + 2)
+
+    value := int16(0)
+    negative := length > 0 && in.read(chars) == '-'
+    index := int16(0)
+    if negative {
+        index = 1
+    }
+    for ; index < length; index++ {
+        c := in.read(chars + index)
+        if c < '0' || c > '9' {
+            break
+        }
+        value = value*10 + c - '0'
+    }
+    if negative {
+        return -value, nil
+    }
+    return value, nil
+}
+
+func stringSetInt(in *Interpreter, args []int16) (int16, error) {
+    this := args[0]
+    in.write(this, 0)
+    for _, c := range strconv.Itoa(int(args[1])) {
+        if _, err := stringAppendChar(in, []int16{this, int16(c)}); err != nil {
+            return 0, err
+        }
+    }
+    return 0, nil
+}
+
+func outputInit(in *Interpreter, args []int16) (int16, error) {
+    in.native.cursorX = 0
+    in.native.cursorY = 0
+    return 0, nil
+}
+
+func outputMoveCursor(in *Interpreter, args []int16) (int16, error) {
+    if args[0] < 0 || args[0] > 22 || args[1] < 0 || args[1] > 63 {
+        return 0, fmt.Errorf("cursor position %d,%d is off the screen", args[0], args[1])
+    }
+    in.native.cursorY = args[0]
+    in.native.cursorX = args[1]
+    return 0, nil
+}
+
+func outputPrintChar(in *Interpreter, args []int16) (int16, error) {
+    bitmap, ok := characterMaps[args[0]]
+    if !ok {
+        bitmap = characterMaps[0]
+    }
+
+    address := screenBase + in.native.cursorY*32*11 + in.native.cursorX/2
+    for _, row := range bitmap {
+        if in.native.cursorX&1 == 1 {
+            in.write(address, in.read(address)&0x00ff|row<<8)
+        } else {
+            in.write(address, in.read(address)&^0x00ff|row)
+        }
+        address += 32
+    }
+
+    if in.native.cursorX == 63 {
+        return outputPrintln(in, nil)
+    }
+    in.native.cursorX++
+    return 0, nil
+}
+
+func outputPrintString(in *Interpreter, args []int16) (int16, error) {
+    length, err := in.Call("String.length", args[0])
+    if err != nil {
+        return 0, err
+    }
+    for i := int16(0); i < length; i++ {
+        c, err := in.Call("String.charAt", args[0], i)
+        if err != nil {
+            return 0, err
+        }
+        if _, err := outputPrintChar(in, []int16{c}); err != nil {
+            return 0, err
+        }
+    }
+    return 0, nil
+}
+
+func outputPrintInt(in *Interpreter, args []int16) (int16, error) {
+    for _, c := range strconv.Itoa(int(args[0])) {
+        if _, err := outputPrintChar(in, []int16{int16(c)}); err != nil {
+            return 0, err
+        }
+    }
+    return 0, nil
+}
+
+func outputPrintln(in *Interpreter, args []int16) (int16, error) {
+    in.native.cursorX = 0
+    if in.native.cursorY < 22 {
+        in.native.cursorY++
+    } else {
+        in.native.cursorY = 0
+    }
+    return 0, nil
+}
+
+func outputBackSpace(in *Interpreter, args []int16) (int16, error) {
+    if in.native.cursorX > 0 {
+        in.native.cursorX--
+    } else if in.native.cursorY > 0 {
+        in.native.cursorY--
+        in.native.cursorX = 63
+    }
+    return 0, nil
+}
+
+func screenInit(in *Interpreter, args []int16) (int16, error) {
+    in.native.color = true
+    return 0, nil
+}
+
+func screenClear(in *Interpreter, args []int16) (int16, error) {
+    for address := screenBase; address < keyboardBase; address++ {
+        in.RAM[address] = 0
+    }
+    return 0, nil
+}
+
+func screenSetColor(in *Interpreter, args []int16) (int16, error) {
+    in.native.color = args[0] != 0
+    return 0, nil
+}
+
+func (in *Interpreter) drawPixel(x, y int16) error {
+    if x < 0 || x > 511 || y < 0 || y > 255 {
+        return fmt.Errorf("pixel %d,%d is off the screen", x, y)
+    }
+    address := screenBase + y*32 + x/16
+    mask := int16(1) << (x & 15)
+    if in.native.color {
+        in.write(address, in.read(address)|mask)
+    } else {
+        in.write(address, in.read(address)&^mask)
+    }
+    return nil
+}
+
+func screenDrawPixel(in *Interpreter, args []int16) (int16, error) {
+    return 0, in.drawPixel(args[0], args[1])
+}
+
+func screenDrawLine(in *Interpreter, args []int16) (int16, error) {
+    x1, y1, x2, y2 := args[0], args[1], args[2], args[3]
+    dx, dy := x2-x1, y2-y1
+    stepX, stepY := int16(1), int16(1)
+    if dx < 0 {
+        dx, stepX = -dx, -1
+    }
+    if dy < 0 {
+        dy, stepY = -dy, -1
+    }
+
+    a, b, diff := int16(0), int16(0), int16(0)
+    for a <= dx && b <= dy {
+        if err := in.drawPixel(x1+a*stepX, y1+b*stepY); err != nil {
+            return 0, err
+        }
+        switch {
+        case dy == 0:
+            a++
+        case dx == 0:
+            b++
+        case diff < 0:
+            a++
+            diff += dy
+        default:
+            b++
+            diff -= dx
+        }
+    }
+    return 0, nil
+}
+
+func screenDrawRectangle(in *Interpreter, args []int16) (int16, error) {
+    for y := args[1]; y <= args[3]; y++ {
+        for x := args[0]; x <= args[2]; x++ {
+            if err := in.drawPixel(x, y); err != nil {
+                return 0, err
+            }
+        }
+    }
+    return 0, nil
+}
+
+func screenDrawCircle(in *Interpreter, args []int16) (int16, error) {
+    cx, cy, r := args[0], args[1], args[2]
+    if r < 0 || r > 181 {
+        return 0, fmt.Errorf("invalid circle radius %d", r)
+    }
+    for dy := -r; dy <= r; dy++ {
+        dx, _ := mathSqrt(in, []int16{r*r - dy*dy})
+        for x := cx - dx; x <= cx+dx; x++ {
+            if err := in.drawPixel(x, cy+dy); err != nil {
+                return 0, err
+            }
+        }
+    }
+    return 0, nil
+}
+
+func sysHalt(in *Interpreter, args []int16) (int16, error) {
+    return 0, errHalted
+}
+
+func sysError(in *Interpreter, args []int16) (int16, error) {
+    return 0, fmt.Errorf("Sys.error(%d)", args[0])
+}
+
+var characterMaps = map[int16][11]int16{
+    0: {63, 63, 63, 63, 63, 63, 63, 63, 63, 0, 0},
+    32: {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+    33: {12, 30, 30, 30, 12, 12, 0, 12, 12, 0, 0},
+    34: {54, 54, 20, 0, 0, 0, 0, 0, 0, 0, 0},
+    35: {0, 18, 18, 63, 18, 18, 63, 18, 18, 0, 0},
+    36: {12, 30, 51, 3, 30, 48, 51, 30, 12, 12, 0},
+    37: {0, 0, 35, 51, 24, 12, 6, 51, 49, 0, 0},
+    38: {12, 30, 30, 12, 54, 27, 27, 27, 54, 0, 0},
+    39: {12, 12, 6, 0, 0, 0, 0, 0, 0, 0, 0},
+    40: {24, 12, 6, 6, 6, 6, 6, 12, 24, 0, 0},
+    41: {6, 12, 24, 24, 24, 24, 24, 12, 6, 0, 0},
+    42: {0, 0, 0, 51, 30, 63, 30, 51, 0, 0, 0},
+    43: {0, 0, 0, 12, 12, 63, 12, 12, 0, 0, 0},
+    44: {0, 0, 0, 0, 0, 0, 0, 12, 12, 6, 0},
+    45: {0, 0, 0, 0, 0, 63, 0, 0, 0, 0, 0},
+    46: {0, 0, 0, 0, 0, 0, 0, 12, 12, 0, 0},
+    47: {0, 0, 32, 48, 24, 12, 6, 3, 1, 0, 0},
+    48: {12, 30, 51, 51, 51, 51, 51, 30, 12, 0, 0},
+    49: {12, 14, 15, 12, 12, 12, 12, 12, 63, 0, 0},
+    50: {30, 51, 48, 24, 12, 6, 3, 51, 63, 0, 0},
+    51: {30, 51, 48, 48, 28, 48, 48, 51, 30, 0, 0},
+    52: {16, 24, 28, 26, 25, 63, 24, 24, 60, 0, 0},
+    53: {63, 3, 3, 31, 48, 48, 48, 51, 30, 0, 0},
+    54: {28, 6, 3, 3, 31, 51, 51, 51, 30, 0, 0},
+    55: {63, 49, 48, 48, 24, 12, 12, 12, 12, 0, 0},
+    56: {30, 51, 51, 51, 30, 51, 51, 51, 30, 0, 0},
+    57: {30, 51, 51, 51, 62, 48, 48, 24, 14, 0, 0},
+    58: {0, 0, 12, 12, 0, 0, 12, 12, 0, 0, 0},
+    59: {0, 0, 12, 12, 0, 0, 12, 12, 6, 0, 0},
+    60: {0, 0, 24, 12, 6, 3, 6, 12, 24, 0, 0},
+    61: {0, 0, 0, 63, 0, 0, 63, 0, 0, 0, 0},
+    62: {0, 0, 3, 6, 12, 24, 12, 6, 3, 0, 0},
+    63: {30, 51, 51, 24, 12, 12, 0, 12, 12, 0, 0},
+    64: {30, 51, 51, 59, 59, 59, 27, 3, 30, 0, 0},
+    65: {12, 30, 51, 51, 63, 51, 51, 51, 51, 0, 0},
+    66: {31, 51, 51, 51, 31, 51, 51, 51, 31, 0, 0},
+    67: {28, 54, 35, 3, 3, 3, 35, 54, 28, 0, 0},
+    68: {15, 27, 51, 51, 51, 51, 51, 27, 15, 0, 0},
+    69: {63, 51, 35, 11, 15, 11, 35, 51, 63, 0, 0},
+    70: {63, 51, 35, 11, 15, 11, 3, 3, 3, 0, 0},
+    71: {28, 54, 35, 3, 59, 51, 51, 54, 44, 0, 0},
+    72: {51, 51, 51, 51, 63, 51, 51, 51, 51, 0, 0},
+    73: {30, 12, 12, 12, 12, 12, 12, 12, 30, 0, 0},
+    74: {60, 24, 24, 24, 24, 24, 27, 27, 14, 0, 0},
+    75: {51, 51, 51, 27, 15, 27, 51, 51, 51, 0, 0},
+    76: {3, 3, 3, 3, 3, 3, 35, 51, 63, 0, 0},
+    77: {33, 51, 63, 63, 51, 51, 51, 51, 51, 0, 0},
+    78: {51, 51, 55, 55, 63, 59, 59, 51, 51, 0, 0},
+    79: {30, 51, 51, 51, 51, 51, 51, 51, 30, 0, 0},
+    80: {31, 51, 51, 51, 31, 3, 3, 3, 3, 0, 0},
+    81: {30, 51, 51, 51, 51, 51, 63, 59, 30, 48, 0},
+    82: {31, 51, 51, 51, 31, 27, 51, 51, 51, 0, 0},
+    83: {30, 51, 51, 6, 28, 48, 51, 51, 30, 0, 0},
+    84: {63, 63, 45, 12, 12, 12, 12, 12, 30, 0, 0},
+    85: {51, 51, 51, 51, 51, 51, 51, 51, 30, 0, 0},
+    86: {51, 51, 51, 51, 51, 30, 30, 12, 12, 0, 0},
+    87: {51, 51, 51, 51, 51, 63, 63, 63, 18, 0, 0},
+    88: {51, 51, 30, 30, 12, 30, 30, 51, 51, 0, 0},
+    89: {51, 51, 51, 51, 30, 12, 12, 12, 30, 0, 0},
+    90: {63, 51, 49, 24, 12, 6, 35, 51, 63, 0, 0},
+    91: {30, 6, 6, 6, 6, 6, 6, 6, 30, 0, 0},
+    92: {0, 0, 1, 3, 6, 12, 24, 48, 32, 0, 0},
+    93: {30, 24, 24, 24, 24, 24, 24, 24, 30, 0, 0},
+    94: {8, 28, 54, 0, 0, 0, 0, 0, 0, 0, 0},
+    95: {0, 0, 0, 0, 0, 0, 0, 0, 0, 63, 0},
+    96: {6, 12, 24, 0, 0, 0, 0, 0, 0, 0, 0},
+    97: {0, 0, 0, 14, 24, 30, 27, 27, 54, 0, 0},
+    98: {3, 3, 3, 15, 27, 51, 51, 51, 30, 0, 0},
+    99: {0, 0, 0, 30, 51, 3, 3, 51, 30, 0, 0},
+    100: {48, 48, 48, 60, 54, 51, 51, 51, 30, 0, 0},
+    101: {0, 0, 0, 30, 51, 63, 3, 51, 30, 0, 0},
+    102: {28, 54, 38, 6, 15, 6, 6, 6, 15, 0, 0},
+    103: {0, 0, 30, 51, 51, 51, 62, 48, 51, 30, 0},
+    104: {3, 3, 3, 27, 55, 51, 51, 51, 51, 0, 0},
+    105: {12, 12, 0, 14, 12, 12, 12, 12, 30, 0, 0},
+    106: {48, 48, 0, 56, 48, 48, 48, 48, 51, 30, 0},
+    107: {3, 3, 3, 51, 27, 15, 15, 27, 51, 0, 0},
+    108: {14, 12, 12, 12, 12, 12, 12, 12, 30, 0, 0},
+    109: {0, 0, 0, 29, 63, 43, 43, 43, 43, 0, 0},
+    110: {0, 0, 0, 29, 51, 51, 51, 51, 51, 0, 0},
+    111: {0, 0, 0, 30, 51, 51, 51, 51, 30, 0, 0},
+    112: {0, 0, 0, 30, 51, 51, 51, 31, 3, 3, 0},
+    113: {0, 0, 0, 30, 51, 51, 51, 62, 48, 48, 0},
+    114: {0, 0, 0, 29, 55, 51, 3, 3, 7, 0, 0},
+    115: {0, 0, 0, 30, 51, 6, 24, 51, 30, 0, 0},
+    116: {4, 6, 6, 15, 6, 6, 6, 54, 28, 0, 0},
+    117: {0, 0, 0, 27, 27, 27, 27, 27, 54, 0, 0},
+    118: {0, 0, 0, 51, 51, 51, 51, 30, 12, 0, 0},
+    119: {0, 0, 0, 51, 51, 51, 63, 63, 18, 0, 0},
+    120: {0, 0, 0, 51, 30, 12, 12, 30, 51, 0, 0},
+    121: {0, 0, 0, 51, 51, 51, 62, 48, 24, 15, 0},
+    122: {0, 0, 0, 63, 27, 12, 6, 51, 63, 0, 0},
+    123: {56, 12, 12, 12, 7, 12, 12, 12, 56, 0, 0},
+    124: {12, 12, 12, 12, 12, 12, 12, 12, 12, 0, 0},
+    125: {7, 12, 12, 12, 56, 12, 12, 12, 7, 0, 0},
+    126: {38, 45, 25, 0, 0, 0, 0, 0, 0, 0, 0},
+}
@@ -0,0 +1,67 @@
+package vmtranslator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	compiler "nand2tetris/07.compiler"
+)
+
+// The native Memory class must leave the same heap as Memory.jack: the
+// reused block of 4 words is split even though no room is left after the
+// 2 allocated, and an allocation that fits nowhere returns -1.
+func TestNativeMemoryMatchesMemoryJack(t *testing.T) {
+    directory := writeProgram(t, map[string]string{"Main.vm": `function Main.test 0
+call Memory.init 0
+pop temp 0
+push constant 4
+call Memory.alloc 1
+pop static 0
+push constant 5
+call Memory.alloc 1
+pop static 1
+push static 0
+call Memory.deAlloc 1
+pop temp 0
+push constant 2
+call Memory.alloc 1
+pop static 2
+push constant 20000
+call Memory.alloc 1
+pop static 3
+push constant 1
+call Memory.alloc 1
+pop static 4
+push static 1
+call Memory.deAlloc 1
+pop temp 0
+push constant 3
+call Memory.alloc 1
+pop static 5
+push static 3
+return
+`})
+    err := compiler.Build(compiler.Options{
+        Inputs:          []string{filepath.Join("..", "08.OS", "Memory.jack")},
+        OutputDirectory: directory,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := prepare(t, directory, nil)
+
+    want := interpret(t, lines, "Main.test")
+    if want.returned != -1 {
+        t.Fatalf("Memory.alloc(20000) returned %d, want -1", want.returned)
+    }
+    // Memory.jack keeps its free list in statics; the native class does not.
+    for name := range want.statics {
+        if strings.HasPrefix(name, "Memory.") {
+            delete(want.statics, name)
+        }
+    }
+    compareRuns(t, want, interpretWith(t, lines, "Main.test", func(in *Interpreter) error {
+        return in.UseNativeClass("Memory")
+    }))
+}
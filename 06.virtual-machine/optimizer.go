@@ -0,0 +1,312 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type vmFunction struct {
+    name  string
+    lines []string
+}
+
+func (vm *VMTranslator) optimizeCommands(lines []string) []string {
+    optimized := lines
+    for changed := true; changed; {
+        changed = false
+        for _, pass := range []func([]string) ([]string, bool){
+            foldConstants,
+            fusePushPop,
+            invertBranches,
+            removeDeadCode,
+        } {
+            var passChanged bool
+            optimized, passChanged = pass(optimized)
+            changed = changed || passChanged
+        }
+    }
+
+    vm.reportSavings(lines, optimized)
+    return optimized
+}
+
+func splitFunctions(lines []string) []vmFunction {
+    var functions []vmFunction
+    current := vmFunction{name: ""}
+
+    for _, line := range lines {
+        if strings.HasPrefix(line, "function") {
+            if current.name != "" || len(current.lines) > 0 {
+                functions = append(functions, current)
+            }
+            current = vmFunction{name: strings.Fields(line)[1]}
+        }
+        current.lines = append(current.lines, line)
+    }
+
+    if current.name != "" || len(current.lines) > 0 {
+        functions = append(functions, current)
+    }
+    return functions
+}
+
+func countInstructions(lines []string) int {
+    counter := NewVMTranslator("", false)
+    count := 0
+
+    for _, code := range counter.generate(lines, 0, len(lines)) {
+        for _, line := range strings.Split(code, "\n") {
+            line = strings.TrimSpace(line)
+            if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "(") {
+                continue
+            }
+            count++
+        }
+    }
+    return count
+}
+
+func (vm *VMTranslator) reportSavings(before, after []string) {
+    afterFunctions := make(map[string][]string)
+    for _, function := range splitFunctions(after) {
+        afterFunctions[function.name] = function.lines
+    }
+
+    totalBefore, totalAfter := 0, 0
+    fmt.Fprintln(os.Stderr, "Optimizer savings (VM commands, Hack instructions):")
+    for _, function := range splitFunctions(before) {
+        optimized := afterFunctions[function.name]
+        instructionsBefore := countInstructions(function.lines)
+        instructionsAfter := countInstructions(optimized)
+        totalBefore += instructionsBefore
+        totalAfter += instructionsAfter
+
+        name := function.name
+        if name == "" {
+            name = "(top level)"
+        }
+        fmt.Fprintf(os.Stderr, "  %-32s %5d -> %5d  %6d -> %6d  (-%d)\n",
+            name, len(function.lines), len(optimized),
+            instructionsBefore, instructionsAfter, instructionsBefore-instructionsAfter)
+    }
+    fmt.Fprintf(os.Stderr, "  %-32s %5d -> %5d  %6d -> %6d  (-%d)\n",
+        "total", len(before), len(after), totalBefore, totalAfter, totalBefore-totalAfter)
+}
+
+func constantTerm(lines []string, start int) (int16, int, bool) {
+    if start >= len(lines) {
+        return 0, 0, false
+    }
+
+    args := strings.Fields(lines[start])
+    if len(args) < 3 || args[0] != "push" || args[1] != "constant" {
+        return 0, 0, false
+    }
+    value, err := strconv.Atoi(args[2])
+    if err != nil {
+        return 0, 0, false
+    }
+
+    result := int16(value)
+    length := 1
+    for start+length < len(lines) {
+        switch lines[start+length] {
+        case "neg":
+            result = -result
+        case "not":
+            result = ^result
+        default:
+            return result, length, true
+        }
+        length++
+    }
+    return result, length, true
+}
+
+func pushConstant(value int16) []string {
+    if value >= 0 {
+        return []string{fmt.Sprintf("push constant %d", value)}
+    }
+    if value == -32768 {
+        return []string{"push constant 32767", "not"}
+    }
+    return []string{fmt.Sprintf("push constant %d", -value), "neg"}
+}
+
+// The comparisons mirror writeArithmetic, which tests the sign of x-y and so
+// wraps around for operands more than 32767 apart.
+func evaluateArithmetic(command string, x, y int16) (int16, bool) {
+    boolean := func(b bool) int16 {
+        if b {
+            return -1
+        }
+        return 0
+    }
+
+    switch command {
+    case "add":
+        return x + y, true
+    case "sub":
+        return x - y, true
+    case "and":
+        return x & y, true
+    case "or":
+        return x | y, true
+    case "eq":
+        return boolean(x-y == 0), true
+    case "gt":
+        return boolean(x-y > 0), true
+    case "lt":
+        return boolean(x-y < 0), true
+    }
+    return 0, false
+}
+
+func foldConstants(lines []string) ([]string, bool) {
+    result := make([]string, 0, len(lines))
+    changed := false
+
+    for i := 0; i < len(lines); {
+        x, xLength, ok := constantTerm(lines, i)
+        if !ok {
+            result = append(result, lines[i])
+            i++
+            continue
+        }
+
+        if y, yLength, ok := constantTerm(lines, i+xLength); ok && i+xLength+yLength < len(lines) {
+            if value, ok := evaluateArithmetic(lines[i+xLength+yLength], x, y); ok {
+                result = append(result, pushConstant(value)...)
+                i += xLength + yLength + 1
+                changed = true
+                continue
+            }
+        }
+
+        if i+xLength < len(lines) {
+            next := strings.Fields(lines[i+xLength])
+            if len(next) == 2 && (next[0] == "if-goto" || next[0] == "ifnot-goto") {
+                jumps := x != 0
+                if next[0] == "ifnot-goto" {
+                    jumps = x != -1
+                }
+                if jumps {
+                    result = append(result, "goto "+next[1])
+                }
+                i += xLength + 1
+                changed = true
+                continue
+            }
+        }
+
+        if folded := pushConstant(x); len(folded) < xLength {
+            result = append(result, folded...)
+            changed = true
+        } else {
+            result = append(result, lines[i:i+xLength]...)
+        }
+        i += xLength
+    }
+
+    return result, changed
+}
+
+func fusePushPop(lines []string) ([]string, bool) {
+    result := make([]string, 0, len(lines))
+    changed := false
+
+    for i := 0; i < len(lines); i++ {
+        if i+1 < len(lines) {
+            push := strings.Fields(lines[i])
+            pop := strings.Fields(lines[i+1])
+
+            if len(push) >= 3 && push[0] == "push" && len(pop) >= 3 && pop[0] == "pop" {
+                changed = true
+                i++
+                if strings.Join(push[1:], " ") == strings.Join(pop[1:], " ") {
+                    continue
+                }
+
+                move := fmt.Sprintf("move %s %s %s %s", push[1], push[2], pop[1], pop[2])
+                if len(push) > 3 {
+                    move += " " + push[3]
+                } else if len(pop) > 3 {
+                    move += " " + pop[3]
+                }
+                result = append(result, move)
+                continue
+            }
+        }
+        result = append(result, lines[i])
+    }
+
+    return result, changed
+}
+
+func invertBranches(lines []string) ([]string, bool) {
+    result := make([]string, 0, len(lines))
+    changed := false
+
+    for i := 0; i < len(lines); i++ {
+        if lines[i] == "not" && i+1 < len(lines) {
+            branch := strings.Fields(lines[i+1])
+            if len(branch) == 2 {
+                switch branch[0] {
+                case "if-goto":
+                    result = append(result, "ifnot-goto "+branch[1])
+                    i++
+                    changed = true
+                    continue
+                case "ifnot-goto":
+                    result = append(result, "if-goto "+branch[1])
+                    i++
+                    changed = true
+                    continue
+                }
+            }
+        }
+        result = append(result, lines[i])
+    }
+
+    return result, changed
+}
+
+func removeDeadCode(lines []string) ([]string, bool) {
+    referenced := make(map[string]bool)
+    for _, line := range lines {
+        args := strings.Fields(line)
+        if len(args) == 2 && (args[0] == "goto" || args[0] == "if-goto" || args[0] == "ifnot-goto") {
+            referenced[args[1]] = true
+        }
+    }
+
+    result := make([]string, 0, len(lines))
+    changed := false
+    unreachable := false
+
+    for i, line := range lines {
+        args := strings.Fields(line)
+
+        if strings.HasPrefix(line, "function") || (args[0] == "label" && referenced[args[1]]) {
+            unreachable = false
+        }
+        if unreachable {
+            changed = true
+            continue
+        }
+
+        if args[0] == "goto" && i+1 < len(lines) && lines[i+1] == "label "+args[1] {
+            changed = true
+            continue
+        }
+
+        result = append(result, line)
+        if args[0] == "goto" || args[0] == "return" {
+            unreachable = true
+        }
+    }
+
+    return result, changed
+}
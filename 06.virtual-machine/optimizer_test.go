@@ -0,0 +1,173 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+func optimize(vm *VMTranslator) {
+    vm.optimize = true
+}
+
+// Each program makes one of the passes fire; the optimized program must
+// leave the same statics, heap and return value in the interpreter and in
+// translated code.
+func TestOptimizerPreservesBehaviour(t *testing.T) {
+    tests := []struct {
+        name    string
+        source  string
+        present []string
+        absent  []string
+    }{
+        {
+            name: "constant folding",
+            source: `function Main.test 0
+push constant 7
+push constant 5
+sub
+push constant 3
+add
+pop static 0
+push constant 30000
+push constant 30000
+add
+pop static 1
+push constant 5
+push constant 7
+lt
+pop static 2
+push constant 0
+if-goto SKIP
+push constant 1
+pop static 3
+label SKIP
+push constant 2
+neg
+not
+return
+`,
+            absent: []string{"sub", "add", "lt", "not", "if-goto"},
+        },
+        {
+            name: "push/pop fusion",
+            source: `function Main.test 2
+push constant 3000
+pop pointer 1
+push constant 17
+pop local 0
+push local 0
+pop that 2
+push that 2
+pop static 0
+push static 0
+pop local 1
+push local 1
+push local 1
+pop local 1
+return
+`,
+            present: []string{
+                "move constant 3000 pointer 1",
+                "move constant 17 local 0",
+                "move local 0 that 2",
+                "move that 2 static 0 Main",
+                "move static 0 local 1 Main",
+            },
+            absent: []string{"pop"},
+        },
+        {
+            name: "ifnot-goto",
+            source: `function Main.test 0
+push constant 5
+call Main.clamp 1
+push constant 0
+call Main.clamp 1
+add
+push constant 200
+call Main.clamp 1
+add
+return
+function Main.clamp 0
+push argument 0
+push constant 100
+gt
+not
+if-goto SMALL
+push constant 100
+return
+label SMALL
+push argument 0
+push constant 0
+eq
+not
+not
+if-goto ZERO
+push argument 0
+return
+label ZERO
+push constant 7
+return
+`,
+            present: []string{"ifnot-goto SMALL", "if-goto ZERO"},
+            absent:  []string{"not"},
+        },
+        {
+            name: "dead code",
+            source: `function Main.test 0
+push constant 1
+pop static 0
+goto END
+push constant 99
+pop static 0
+label UNUSED
+push constant 98
+pop static 1
+label END
+push static 0
+return
+push constant 5
+return
+`,
+            absent: []string{"goto", "label UNUSED", "push constant 9", "push constant 5"},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            directory := writeProgram(t, map[string]string{"Main.vm": test.source})
+            lines := prepare(t, directory, optimize)
+            for _, prefix := range test.present {
+                if !hasCommand(lines, prefix) {
+                    t.Errorf("optimized program has no %q:\n%s", prefix, strings.Join(lines, "\n"))
+                }
+            }
+            for _, prefix := range test.absent {
+                if hasCommand(lines, prefix) {
+                    t.Errorf("optimized program still has %q:\n%s", prefix, strings.Join(lines, "\n"))
+                }
+            }
+
+            want := interpret(t, prepare(t, directory, nil), "Main.test")
+            compareRuns(t, want, interpret(t, lines, "Main.test"))
+            unoptimized, _ := emulate(t, directory, "Main.test", nil)
+            compareRuns(t, want, unoptimized)
+            optimized, _ := emulate(t, directory, "Main.test", optimize)
+            compareRuns(t, want, optimized)
+        })
+    }
+}
+
+func TestOptimizerPrograms(t *testing.T) {
+    for name, directory := range map[string]func(t *testing.T) string{
+        "basic":   func(t *testing.T) string { return "testdata/basic" },
+        "objects": func(t *testing.T) string { return jackProgram(t, "objects") },
+    } {
+        t.Run(name, func(t *testing.T) {
+            directory := directory(t)
+            want := interpret(t, prepare(t, directory, nil), "Sys.init")
+            compareRuns(t, want, interpret(t, prepare(t, directory, optimize), "Sys.init"))
+            optimized, _ := emulate(t, directory, "Sys.init", optimize)
+            compareRuns(t, want, optimized)
+        })
+    }
+}
@@ -0,0 +1,152 @@
+package vmtranslator
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// translationUnit is the run of commands that came from one source file.
+// Its return and guard counters start where the previous unit's ended, so
+// translating the units concurrently gives the same labels as one serial
+// pass.
+type translationUnit struct {
+    from       int
+    to         int
+    returns    int
+    guards     int
+    code       []string
+    guardSites []guardSite
+}
+
+// forEach calls fn for 0..n-1 on at most vm.jobs goroutines at a time.
+func (vm *VMTranslator) forEach(n int, fn func(i int)) {
+    jobs := vm.jobs
+    if jobs < 1 {
+        jobs = 1
+    }
+    slots := make(chan struct{}, jobs)
+    var wg sync.WaitGroup
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        slots <- struct{}{}
+        go func(i int) {
+            defer wg.Done()
+            defer func() { <-slots }()
+            fn(i)
+        }(i)
+    }
+    wg.Wait()
+}
+
+// parseFiles parses each file on its own goroutine and appends the results
+// in the order of paths. Every file is parsed even if an earlier one fails,
+// so all the errors are reported together.
+func (vm *VMTranslator) parseFiles(paths []string) error {
+    results := make([][]string, len(paths))
+    errs := make([]error, len(paths))
+
+    vm.forEach(len(paths), func(i int) {
+        file := NewVMTranslator(paths[i], false)
+        errs[i] = file.parse()
+        results[i] = file.parsedContent
+    })
+    if err := errors.Join(errs...); err != nil {
+        return err
+    }
+
+    if vm.functionFiles == nil {
+        vm.functionFiles = make(map[string]int)
+    }
+    for i, lines := range results {
+        for _, line := range lines {
+            if args := strings.Fields(line); args[0] == "function" && len(args) > 1 {
+                vm.functionFiles[args[1]] = i
+            }
+        }
+        vm.parsedContent = append(vm.parsedContent, lines...)
+    }
+    return nil
+}
+
+// translationUnits splits the program where a function from a different
+// source file starts. Commands before the first function, and functions the
+// passes did not take from a file, stay in the unit before them.
+func (vm *VMTranslator) translationUnits(lines []string) []*translationUnit {
+    var units []*translationUnit
+    unit := &translationUnit{}
+    current := -1
+
+    for i, line := range lines {
+        args := strings.Fields(line)
+        if args[0] == "function" {
+            if file, ok := vm.functionFiles[args[1]]; ok && file != current {
+                if current != -1 {
+                    unit.to = i
+                    units = append(units, unit)
+                    unit = &translationUnit{from: i}
+                }
+                current = file
+            }
+        }
+    }
+    unit.to = len(lines)
+    return append(units, unit)
+}
+
+// generateUnits translates every unit concurrently and joins the code in
+// program order. The first round counts the return addresses and guard
+// sites of each unit so the second can number its own from the right place.
+func (vm *VMTranslator) generateUnits(lines []string) []string {
+    units := vm.translationUnits(lines)
+
+    vm.forEach(len(units), func(i int) {
+        unit := units[i]
+        for n := unit.from; n < unit.to; n++ {
+            if strings.HasPrefix(lines[n], "call ") && !vm.isTailCall(lines, n) {
+                unit.returns++
+            }
+        }
+        if vm.guards {
+            scratch := vm.unitTranslator(0, 0)
+            scratch.generate(lines, unit.from, unit.to)
+            unit.guards = len(scratch.guardSites)
+        }
+    })
+
+    returnBase, guardBase := vm.returnCounter, vm.guardBase+len(vm.guardSites)
+    bases := make([][2]int, len(units))
+    for i, unit := range units {
+        bases[i] = [2]int{returnBase, guardBase}
+        returnBase += unit.returns
+        guardBase += unit.guards
+    }
+
+    vm.forEach(len(units), func(i int) {
+        unit := units[i]
+        translator := vm.unitTranslator(bases[i][0], bases[i][1])
+        if vm.cacheTop {
+            unit.code = translator.generateCached(lines, unit.from, unit.to)
+        } else {
+            unit.code = translator.generate(lines, unit.from, unit.to)
+        }
+        unit.guardSites = translator.guardSites
+    })
+
+    var code []string
+    for _, unit := range units {
+        code = append(code, unit.code...)
+        vm.guardSites = append(vm.guardSites, unit.guardSites...)
+    }
+    vm.returnCounter = returnBase
+    return code
+}
+
+func (vm *VMTranslator) unitTranslator(returnBase, guardBase int) *VMTranslator {
+    translator := *vm
+    translator.code = nil
+    translator.guardSites = nil
+    translator.returnCounter = returnBase
+    translator.guardBase = guardBase
+    return &translator
+}
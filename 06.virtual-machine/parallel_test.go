@@ -0,0 +1,50 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+// Files are parsed and functions translated on vm.jobs goroutines; however
+// many there are, the assembly must be the same byte for byte.
+func TestJobsDoNotChangeTheOutput(t *testing.T) {
+    options := map[string]func(vm *VMTranslator){
+        "plain":     func(vm *VMTranslator) {},
+        "optimize":  optimize,
+        "cache-top": func(vm *VMTranslator) { vm.cacheTop = true },
+        "guards":    func(vm *VMTranslator) { vm.guards = true },
+        "inline, tail-calls and remove-dead": func(vm *VMTranslator) {
+            vm.inline = true
+            vm.tailCalls = true
+            vm.removeDead = true
+        },
+    }
+
+    for name, directory := range map[string]func(t *testing.T) string{
+        "basic":   func(t *testing.T) string { return "testdata/basic" },
+        "objects": func(t *testing.T) string { return jackProgram(t, "objects") },
+    } {
+        t.Run(name, func(t *testing.T) {
+            directory := directory(t)
+            for option, configure := range options {
+                translate := func(jobs int) string {
+                    vm := NewVMTranslator(directory, true)
+                    configure(vm)
+                    vm.jobs = jobs
+                    vm.loadBootstrapCode()
+                    if err := vm.translate(); err != nil {
+                        t.Fatal(err)
+                    }
+                    return strings.Join(vm.code, "\n")
+                }
+
+                serial := translate(1)
+                for _, jobs := range []int{2, 8} {
+                    if translate(jobs) != serial {
+                        t.Errorf("%s: the output with %d jobs differs from the output with 1", option, jobs)
+                    }
+                }
+            }
+        })
+    }
+}
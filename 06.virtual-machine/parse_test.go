@@ -0,0 +1,50 @@
+package vmtranslator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Only static operands get the owning file appended, and every command must
+// have exactly the operands its opcode takes.
+func TestParseOperands(t *testing.T) {
+    tests := []struct {
+        line   string
+        parsed string
+        err    string
+    }{
+        {line: "push static 3", parsed: "push static 3 Main"},
+        {line: "pop static 0 // saved", parsed: "pop static 0 Main"},
+        {line: "move static 1 local 0", parsed: "move static 1 local 0 Main"},
+        {line: "move local 0 static 1", parsed: "move local 0 static 1 Main"},
+        {line: "call Foo.staticX 0", parsed: "call Foo.staticX 0"},
+        {line: "label static", parsed: "label static"},
+        {line: "push constant 7 // static", parsed: "push constant 7"},
+        {line: "add 5", err: "unexpected operand 5"},
+        {line: "neg extra", err: "unexpected operand extra"},
+        {line: "push constant 1 Main", err: "unexpected operand Main"},
+        {line: "move constant 1 local 0 Main", err: "unexpected operand Main"},
+        {line: "call Foo.bar 0 1", err: "unexpected operand 1"},
+        {line: "function Main.f", err: "missing operands"},
+    }
+
+    for _, test := range tests {
+        directory := writeProgram(t, map[string]string{"Main.vm": test.line + "\n"})
+        vm := NewVMTranslator(filepath.Join(directory, "Main.vm"), false)
+        err := vm.parse()
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("%q: got error %v, want %q", test.line, err, test.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", test.line, err)
+            continue
+        }
+        if got := strings.Join(vm.parsedContent, "\n"); got != test.parsed {
+            t.Errorf("%q parsed as %q, want %q", test.line, got, test.parsed)
+        }
+    }
+}
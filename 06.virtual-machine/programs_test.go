@@ -0,0 +1,134 @@
+package vmtranslator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	compiler "nand2tetris/07.compiler"
+)
+
+// The tests run the same program with and without a translator option and
+// compare what the program leaves behind. Stack contents and temps are not
+// compared: the passes under test are free to use them differently.
+type runResult struct {
+    returned  int16
+    hasReturn bool
+    statics   map[string]int16
+    memory    []int16
+}
+
+// writeProgram writes VM files into a new directory and returns it.
+func writeProgram(t *testing.T, files map[string]string) string {
+    t.Helper()
+    directory := t.TempDir()
+    for name, source := range files {
+        if err := os.WriteFile(filepath.Join(directory, name), []byte(source), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return directory
+}
+
+// jackProgram compiles testdata/name together with the Jack OS of 08.OS and
+// returns the directory the .vm files were written to.
+func jackProgram(t *testing.T, name string) string {
+    t.Helper()
+    osFiles, err := filepath.Glob(filepath.Join("..", "08.OS", "*.jack"))
+    if err != nil || len(osFiles) == 0 {
+        t.Fatalf("no Jack OS sources in ../08.OS: %v", err)
+    }
+    directory := t.TempDir()
+    err = compiler.Build(compiler.Options{
+        Inputs:          append([]string{filepath.Join("testdata", name)}, osFiles...),
+        OutputDirectory: directory,
+    })
+    if err != nil {
+        t.Fatalf("compiling testdata/%s: %v", name, err)
+    }
+    return directory
+}
+
+// prepare reads the program in directory and runs the VM passes that
+// configure turns on.
+func prepare(t *testing.T, directory string, configure func(vm *VMTranslator)) []string {
+    t.Helper()
+    vm := NewVMTranslator(directory, true)
+    if configure != nil {
+        configure(vm)
+    }
+    if err := vm.prepare(); err != nil {
+        t.Fatal(err)
+    }
+    return vm.parsedContent
+}
+
+// interpret runs lines from entry until it returns or the program halts.
+func interpret(t *testing.T, lines []string, entry string) runResult {
+    t.Helper()
+    return interpretWith(t, lines, entry, nil)
+}
+
+// interpretWith is interpret with setup called on the interpreter before it
+// runs, e.g. to switch OS classes to their native versions.
+func interpretWith(t *testing.T, lines []string, entry string, setup func(in *Interpreter) error) runResult {
+    t.Helper()
+    in, err := NewInterpreterFromLines(lines)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if setup != nil {
+        if err := setup(in); err != nil {
+            t.Fatal(err)
+        }
+    }
+    in.MaxSteps = 50_000_000
+    if err := in.Run(entry); err != nil {
+        t.Fatalf("%s: %v", entry, err)
+    }
+
+    result := runResult{
+        hasReturn: in.pc == returnSentinel,
+        statics:   make(map[string]int16),
+        memory:    append([]int16{}, in.RAM[heapBase:keyboardBase]...),
+    }
+    if result.hasReturn {
+        result.returned = in.RAM[in.RAM[0]-1]
+    }
+    for name, address := range in.statics {
+        result.statics[name] = in.RAM[address]
+    }
+    return result
+}
+
+func compareRuns(t *testing.T, want, got runResult) {
+    t.Helper()
+    if want.hasReturn != got.hasReturn {
+        t.Fatalf("returned from the entry point: %v, want %v", got.hasReturn, want.hasReturn)
+    }
+    if want.returned != got.returned {
+        t.Errorf("returned %d, want %d", got.returned, want.returned)
+    }
+    for name, value := range want.statics {
+        if got.statics[name] != value {
+            t.Errorf("static %s = %d, want %d", name, got.statics[name], value)
+        }
+    }
+    for i := range want.memory {
+        if got.memory[i] != want.memory[i] {
+            t.Errorf("RAM[%d] = %d, want %d", heapBase+i, got.memory[i], want.memory[i])
+            return
+        }
+    }
+}
+
+// hasCommand reports whether lines hold a command starting with prefix.
+func hasCommand(lines []string, prefix string) bool {
+    for _, line := range lines {
+        if strings.HasPrefix(line, prefix) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,229 @@
+package vmtranslator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stackCache emits code that keeps the top of the stack in D across
+// straight-line VM code. While cached is set the value in D is logically on
+// the stack but SP does not count it; it is written back before labels,
+// jumps, calls and returns so every jump target sees an ordinary stack.
+type stackCache struct {
+    vm     *VMTranslator
+    cached bool
+    code   []string
+}
+
+func (vm *VMTranslator) generateCached(lines []string, from, to int) []string {
+    sc := &stackCache{vm: vm}
+
+    for i := from; i < to; i++ {
+        line := lines[i]
+        switch vm.commandType(line) {
+        case "C_PUSH":
+            sc.push(line)
+        case "C_POP":
+            sc.pop(line)
+        case "C_ARITHMETIC":
+            sc.arithmetic(line, i)
+        case "C_LABEL":
+            sc.flush()
+            sc.emit(vm.writeLabel(line))
+        case "C_GOTO":
+            sc.flush()
+            sc.emit(vm.writeGoto(line))
+        case "C_IF":
+            sc.branch(line, "")
+        case "C_IF_NOT":
+            sc.branch(line, `
+		D=D+1`)
+        case "C_MOVE":
+            sc.flush()
+            sc.emit(vm.writeMove(line))
+        case "C_FUNCTION":
+            sc.flush()
+            sc.emit(vm.writeFunction(line))
+        case "C_CALL":
+            sc.flush()
+            if vm.isTailCall(lines, i) {
+                sc.emit(vm.writeTailCall(line))
+            } else {
+                sc.emit(vm.writeCall(line))
+            }
+        case "C_RETURN":
+            sc.flush()
+            if i == 0 || !vm.isTailCall(lines, i-1) {
+                sc.emit(vm.writeReturn(line))
+            }
+        default:
+            fmt.Printf("Unknown command: %s\n", line)
+        }
+    }
+
+    sc.flush()
+    return sc.code
+}
+
+func (sc *stackCache) emit(code string) {
+    sc.code = append(sc.code, code)
+}
+
+func (sc *stackCache) flush() {
+    if !sc.cached {
+        return
+    }
+    sc.emit(`// flush cached top of stack
+			@SP
+			M=M+1
+			A=M-1
+			M=D`)
+    sc.cached = false
+}
+
+func (sc *stackCache) load() string {
+    if sc.cached {
+        return ""
+    }
+    sc.cached = true
+    return `
+			@SP
+			AM=M-1
+			D=M`
+}
+
+func (sc *stackCache) push(line string) {
+    args := strings.Fields(line)
+    segment, index := args[1], args[2]
+    var fileName string
+    if len(args) > 3 {
+        fileName = args[3]
+    }
+
+    sc.flush()
+    if segment == "constant" {
+        sc.emit(fmt.Sprintf(`// %s
+			@%s
+			D=A`, line, index))
+    } else if address, direct := sc.vm.segmentAddress(segment, index, fileName); direct {
+        sc.emit(fmt.Sprintf(`// %s
+			@%s
+			D=M`, line, address))
+    } else {
+        sc.emit(fmt.Sprintf(`// %s
+			@%s
+			D=M
+			@%s
+			A=D+A
+			D=M`, line, address, index))
+    }
+    sc.cached = true
+}
+
+func (sc *stackCache) pop(line string) {
+    args := strings.Fields(line)
+    segment, index := args[1], args[2]
+    var fileName string
+    if len(args) > 3 {
+        fileName = args[3]
+    }
+
+    load := sc.load()
+    sc.cached = false
+
+    address, direct := sc.vm.segmentAddress(segment, index, fileName)
+    if direct {
+        sc.emit(fmt.Sprintf(`// %s%s
+			@%s
+			M=D`, line, load, address))
+        return
+    }
+
+    if offset := parseInt(index); offset <= 2 {
+        store := fmt.Sprintf(`
+			@%s
+			A=M`, address)
+        for i := 0; i < offset; i++ {
+            store += `
+			A=A+1`
+        }
+        sc.emit(fmt.Sprintf(`// %s%s%s
+			M=D`, line, load, store))
+        return
+    }
+
+    sc.emit(fmt.Sprintf(`// %s%s
+			@R13
+			M=D
+			@%s
+			D=M
+			@%s
+			D=D+A
+			@R14
+			M=D
+			@R13
+			D=M
+			@R14
+			A=M
+			M=D`, line, load, address, index))
+}
+
+func (sc *stackCache) arithmetic(line string, index int) {
+    load := sc.load()
+
+    var code string
+    switch line {
+    case "add":
+        code = `
+			@SP
+			AM=M-1
+			D=D+M`
+    case "sub":
+        code = `
+			@SP
+			AM=M-1
+			D=M-D`
+    case "and":
+        code = `
+			@SP
+			AM=M-1
+			D=D&M`
+    case "or":
+        code = `
+			@SP
+			AM=M-1
+			D=D|M`
+    case "neg":
+        code = `
+			D=-D`
+    case "not":
+        code = `
+			D=!D`
+    case "eq", "gt", "lt":
+        label := fmt.Sprintf("%s%d", strings.ToUpper(line), index)
+        code = fmt.Sprintf(`
+			@SP
+			AM=M-1
+			D=M-D
+			@%s
+			D;J%s
+			D=0
+			@%s.END
+			0;JMP
+			(%s)
+			D=-1
+			(%s.END)`, label, strings.ToUpper(line), label, label, label)
+    }
+
+    sc.emit(fmt.Sprintf("// %s%s%s", line, load, code))
+}
+
+func (sc *stackCache) branch(line string, test string) {
+    label := strings.Fields(line)[1]
+    load := sc.load()
+    sc.cached = false
+
+    sc.emit(fmt.Sprintf(`// %s%s%s
+		@%s
+		D;JNE`, line, load, test, label))
+}
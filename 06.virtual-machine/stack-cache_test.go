@@ -0,0 +1,30 @@
+package vmtranslator
+
+import (
+	"testing"
+)
+
+// Caching the top of the stack in D must not change what a program does and
+// must make it execute fewer Hack instructions. Run with -v to see the
+// counts.
+func TestCacheTopInstructionCount(t *testing.T) {
+    for name, directory := range map[string]func(t *testing.T) string{
+        "basic":   func(t *testing.T) string { return "testdata/basic" },
+        "objects": func(t *testing.T) string { return jackProgram(t, "objects") },
+    } {
+        t.Run(name, func(t *testing.T) {
+            directory := directory(t)
+            want, plain := emulate(t, directory, "Sys.init", nil)
+            got, cached := emulate(t, directory, "Sys.init", func(vm *VMTranslator) {
+                vm.cacheTop = true
+            })
+            compareRuns(t, want, got)
+
+            t.Logf("%d instructions, %d with -cache-top (%.1f%%)",
+                plain.cycles, cached.cycles, 100*float64(cached.cycles-plain.cycles)/float64(plain.cycles))
+            if cached.cycles >= plain.cycles {
+                t.Errorf("-cache-top executed %d instructions, not fewer than %d", cached.cycles, plain.cycles)
+            }
+        })
+    }
+}
@@ -0,0 +1,82 @@
+package vmtranslator
+
+import (
+	"testing"
+)
+
+// sum calls itself 2000 deep in tail position, and ping and pong, which take
+// a different number of arguments, call each other 1000 deep. Without
+// -tail-calls the frames run far past the end of the stack at 2047.
+func TestTailCallsKeepTheStackFlat(t *testing.T) {
+    directory := writeProgram(t, map[string]string{"Main.vm": `function Main.test 0
+push constant 2000
+push constant 0
+call Main.sum 2
+push constant 1000
+call Main.ping 1
+add
+return
+function Main.sum 0
+push argument 0
+if-goto SUM_MORE
+push argument 1
+return
+label SUM_MORE
+push argument 0
+push constant 1
+sub
+push argument 1
+push argument 0
+add
+call Main.sum 2
+return
+function Main.ping 1
+push argument 0
+pop local 0
+push local 0
+if-goto PING_MORE
+push static 0
+return
+label PING_MORE
+push local 0
+push constant 1
+sub
+push constant 7
+push local 0
+call Main.pong 3
+return
+function Main.pong 0
+push static 0
+push argument 1
+add
+pop static 0
+push argument 0
+call Main.ping 1
+return
+`})
+
+    want := interpret(t, prepare(t, directory, nil), "Main.test")
+    sum, ping := 2000*2001/2, 7*1000
+    if result := int16(sum + ping); want.returned != result {
+        t.Fatalf("interpreter returned %d, want %d", want.returned, result)
+    }
+    // The frames of the plain run are left behind in the heap, so only the
+    // results are compared.
+    want.memory = nil
+    plain, cpu := emulate(t, directory, "Main.test", nil)
+    compareRuns(t, want, plain)
+    if cpu.maxSP <= 2047 {
+        t.Fatalf("without -tail-calls the stack only reached %d; the test recursion is not deep enough", cpu.maxSP)
+    }
+
+    for _, cacheTop := range []bool{false, true} {
+        got, cpu := emulate(t, directory, "Main.test", func(vm *VMTranslator) {
+            vm.tailCalls = true
+            vm.cacheTop = cacheTop
+        })
+        compareRuns(t, want, got)
+        if cpu.maxSP > 2047 {
+            t.Errorf("with -tail-calls (cache-top %v) the stack reached %d", cacheTop, cpu.maxSP)
+        }
+    }
+}
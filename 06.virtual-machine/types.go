@@ -1,10 +1,106 @@
-package main
+package vmtranslator
 
 type VMTranslator struct {
-    file           string
-    fileName       string
-    parsedContent  []string
-    code           []string
-    returnCounter  int
-    isDirectory    bool
+    file             string
+    fileName         string
+    directory        string
+    parsedContent    []string
+    code             []string
+    returnCounter    int
+    isDirectory      bool
+    optimize         bool
+    cacheTop         bool
+    removeDead       bool
+    entry            string
+    keep             []string
+    inline           bool
+    inlineThreshold  int
+    inlineCounter    int
+    tailCalls        bool
+    guards           bool
+    guardSites       []guardSite
+    guardBase        int
+    jobs             int
+    functionFiles    map[string]int
+}
+
+type vmCommand struct {
+    kind         string
+    segment      string
+    index        int
+    name         string
+    file         string
+    target       int
+    address      int
+    destSegment  string
+    destIndex    int
+    destAddress  int
+}
+
+type Interpreter struct {
+    RAM          [32768]int16
+    lines        []string
+    commands     []vmCommand
+    functions    map[string]int
+    owners       []int
+    statics      map[string]int
+    pc           int
+    steps        int
+    MaxSteps     int
+    halted       bool
+    callers      []int
+    natives      map[string]NativeFunction
+    native       nativeState
+    heap         *heapChecker
+    numLocals    map[string]int
+}
+
+type stackFrame struct {
+    Function       string   `json:"function"`
+    Command        int      `json:"command"`
+    ReturnAddress  int      `json:"returnAddress"`
+    ReturnTo       string   `json:"returnTo"`
+    SavedLCL       int16    `json:"savedLCL"`
+    SavedARG       int16    `json:"savedARG"`
+    SavedTHIS      int16    `json:"savedTHIS"`
+    SavedTHAT      int16    `json:"savedTHAT"`
+    Arguments      []int16  `json:"arguments"`
+    Locals         []int16  `json:"locals"`
+    Stack          []int16  `json:"stack"`
+}
+
+type heapBlock struct {
+    Address      int16  `json:"address"`
+    Length       int16  `json:"length"`
+    Free         bool   `json:"free"`
+}
+
+type memoryDump struct {
+    Function     string        `json:"function"`
+    Error        string        `json:"error,omitempty"`
+    Steps        int           `json:"steps"`
+    SP           int16         `json:"sp"`
+    Frames       []stackFrame  `json:"frames"`
+    FreeBlocks   int16         `json:"freeBlocks"`
+    Heap         []heapBlock   `json:"heap"`
+    Problems     []string      `json:"problems"`
+}
+
+type interpreterOptions struct {
+    entry        string
+    steps        int
+    ranges       string
+    native       []string
+    dump         string
+    checkHeap    bool
+    guards       bool
+}
+
+type NativeFunction func(in *Interpreter, args []int16) (int16, error)
+
+type nativeState struct {
+    freeBlocks   int16
+    cursorX      int16
+    cursorY      int16
+    color        bool
 }
\ No newline at end of file
@@ -1,10 +1,36 @@
-package main
+package compiler
 
 import (
-	"regexp"
+	"fmt"
+	"os"
+	"strings"
 )
 
-func NewAnalyzer(directoryPath string) *Analyzer {
+// Options are the settings of Build, the flags of the command line.
+type Options struct {
+	Inputs          []string
+	Recursive       bool
+	OutputDirectory string
+	OSDirectory     string
+	Werror          bool
+	TypeRules       map[string]string
+}
+
+// Build compiles the .jack files and directories in Options.Inputs to .vm
+// files. Warnings are printed to stderr; errors are returned and leave no
+// output behind.
+func Build(options Options) error {
+	analyzer := NewAnalyzer(options.Inputs)
+	analyzer.recursive = options.Recursive
+	analyzer.outputDirectory = options.OutputDirectory
+	analyzer.osDirectory = options.OSDirectory
+	analyzer.werror = options.Werror
+	analyzer.typeRules = options.TypeRules
+	return analyzer.Compile()
+}
+
+// NewAnalyzer compiles the .jack files and directories in inputs.
+func NewAnalyzer(inputs []string) *Analyzer {
 	var tokenizedFiles []TokenizedFile
 
 	parser := NewParser()
@@ -13,7 +39,7 @@ func NewAnalyzer(directoryPath string) *Analyzer {
 
 	return &Analyzer{
 		parsedContent:     []ParsedContent{},
-		directoryPath:     directoryPath,
+		inputs:            inputs,
 		parser:            parser,
 		tokenizer:         tokenizer,
 		tokenizedFiles:    tokenizedFiles,
@@ -23,7 +49,7 @@ func NewAnalyzer(directoryPath string) *Analyzer {
 
 func (a *Analyzer) Initialize() ([]ParsedContent, error) {
 	var err error
-	a.parsedContent, err = a.parser.ReadFilesFromDirectory(a.directoryPath)
+	a.parsedContent, err = a.parser.ReadFiles(a.inputs, a.recursive)
 	return a.parsedContent, err
 }
 
@@ -34,38 +60,124 @@ func (a *Analyzer) PrepareContent() error {
 	}
 
 	for _, content := range a.parsedContent {
-		tokenizedLines := []Tokenized{}
-		
-		re := regexp.MustCompile(`"[^"]*"|\S+`)
-
-		for _, line := range content.Content {
-			tokens := re.FindAllString(line, -1)
-			
-			for _, token := range tokens {
-				tokenized, err := a.tokenizer.Tokenize(token)
-				if err != nil {
-					return err
-				}
-
-				tokenizedLines = append(tokenizedLines, tokenized)
-			}
+		tokens, err := NewLexer(content.Filename, content.Source, a.tokenizer).Tokenize()
+		if err != nil {
+			a.errors = append(a.errors, err.(*CompileError))
+			a.compilationEngine.Unparsed(content.Filename)
+			continue
 		}
 
 		a.tokenizedFiles = append(a.tokenizedFiles, TokenizedFile{
 			Filename: content.Filename,
-			Content:  tokenizedLines,
+			Content:  tokens,
 		})
 	}
 
 	return nil
 }
 
+// Compile compiles every file and writes the .vm files only when none of
+// them has an error, or, with werror, a warning. Otherwise nothing is
+// written and all errors are returned together.
 func (a *Analyzer) Compile() error {
 	err := a.PrepareContent()
 	if err != nil {
 		return err
 	}
 
+	osClasses, err := a.OSClasses()
+	if err != nil {
+		return err
+	}
+
 	a.compilationEngine.UpdateFiles(a.tokenizedFiles)
-	return a.compilationEngine.Compile()
-}
\ No newline at end of file
+	a.compilationEngine.typeRules = a.typeRules
+	outputFiles, errs := a.compilationEngine.Compile(osClasses)
+	errs = append(a.errors, errs...).withSource(append(a.parsedContent, a.osContent...))
+	if errs.HasErrors() || (a.werror && len(errs) > 0) {
+		return errs
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, errs)
+	}
+	if a.layout {
+		fmt.Print(strings.Join(a.compilationEngine.layouts, "\n"))
+	}
+	return a.compilationEngine.WriteFiles(outputFiles, a.outputDirectory)
+}
+
+// Analyze writes the front end's view of each file instead of VM code:
+// "tokens" writes the project 10 token file xxxT.xml, "xml" adds the parse
+// tree file xxx.xml and "ast" writes the syntax tree as JSON to xxx.ast.
+func (a *Analyzer) Analyze(emit string) error {
+	err := a.PrepareContent()
+	if err != nil {
+		return err
+	}
+
+	var outputFiles []OutputFile
+	errs := a.errors
+	for _, file := range a.tokenizedFiles {
+		class, err := NewClassParser(file.Filename, file.Content).ParseClass()
+		if err != nil {
+			errs = append(errs, err.(*CompileError))
+			continue
+		}
+
+		name := strings.TrimSuffix(file.Filename, ".jack")
+		switch emit {
+		case "tokens":
+			outputFiles = append(outputFiles, OutputFile{Filename: name + "T.xml", Code: TokensXML(file.Content)})
+		case "xml":
+			outputFiles = append(outputFiles,
+				OutputFile{Filename: name + "T.xml", Code: TokensXML(file.Content)},
+				OutputFile{Filename: name + ".xml", Code: ClassXML(class)},
+			)
+		case "ast":
+			code, err := ClassJSON(class)
+			if err != nil {
+				return err
+			}
+			outputFiles = append(outputFiles, OutputFile{Filename: name + ".ast", Code: code})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs.withSource(a.parsedContent)
+	}
+	return a.compilationEngine.WriteFiles(outputFiles, a.outputDirectory)
+}
+
+// OSClasses parses the OS that calls are resolved against: the .jack files
+// in osDirectory when it is set, otherwise the built-in signatures. Errors
+// in the OS sources are collected with the project's own.
+func (a *Analyzer) OSClasses() ([]*Class, error) {
+	if a.osDirectory == "" {
+		tokens, err := NewLexer("os-signatures", osSignatures, a.tokenizer).Tokenize()
+		if err != nil {
+			return nil, err
+		}
+		return NewClassParser("os-signatures", tokens).ParseClasses()
+	}
+
+	files, err := NewParser().ReadFiles([]string{a.osDirectory}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var classes []*Class
+	for _, file := range files {
+		a.osContent = append(a.osContent, file)
+
+		tokens, err := NewLexer(file.Filename, file.Source, a.tokenizer).Tokenize()
+		if err == nil {
+			var class *Class
+			if class, err = NewClassParser(file.Filename, tokens).ParseClass(); err == nil {
+				classes = append(classes, class)
+				continue
+			}
+		}
+		a.errors = append(a.errors, err.(*CompileError))
+	}
+	return classes, nil
+}
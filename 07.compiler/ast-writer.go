@@ -0,0 +1,70 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// astNode keeps the fields of a node in declaration order, after the name
+// of its type.
+type astNode struct {
+	kind   string
+	fields []string
+	values []any
+}
+
+func (n astNode) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(`{"node":`)
+	kind, _ := json.Marshal(n.kind)
+	buffer.Write(kind)
+
+	for i, field := range n.fields {
+		value, err := json.Marshal(n.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(`,"` + field + `":`)
+		buffer.Write(value)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes(), nil
+}
+
+// ClassJSON writes the AST of a class as indented JSON. Each node is an
+// object whose "node" names its type, and positions are file:line:col.
+func ClassJSON(class *Class) (string, error) {
+	data, err := json.MarshalIndent(astValue(reflect.ValueOf(class)), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+func astValue(value reflect.Value) any {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return astValue(value.Elem())
+	case reflect.Slice:
+		list := make([]any, value.Len())
+		for i := range list {
+			list[i] = astValue(value.Index(i))
+		}
+		return list
+	case reflect.Struct:
+		if position, ok := value.Interface().(Position); ok {
+			return position.String()
+		}
+		node := astNode{kind: value.Type().Name()}
+		for i := 0; i < value.NumField(); i++ {
+			node.fields = append(node.fields, value.Type().Field(i).Name)
+			node.values = append(node.values, astValue(value.Field(i)))
+		}
+		return node
+	}
+	return value.Interface()
+}
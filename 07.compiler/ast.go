@@ -0,0 +1,148 @@
+package compiler
+
+// The AST follows the Jack grammar closely: an expression keeps its terms
+// and operators in source order, and parentheses stay in the tree, so both
+// the code generator and the XML writer can walk it without re-reading
+// tokens.
+
+type Class struct {
+	Position    Position
+	Name        string
+	VarDecs     []*ClassVarDec
+	Subroutines []*Subroutine
+}
+
+type VarName struct {
+	Position Position
+	Name     string
+}
+
+type ClassVarDec struct {
+	Position Position
+	Kind     string
+	Type     string
+	Names    []VarName
+}
+
+type Parameter struct {
+	Position Position
+	Type     string
+	Name     VarName
+}
+
+type VarDec struct {
+	Position Position
+	Type     string
+	Names    []VarName
+}
+
+type Subroutine struct {
+	Position   Position
+	Kind       string
+	ReturnType string
+	Name       string
+	Parameters []*Parameter
+	VarDecs    []*VarDec
+	Statements []Statement
+}
+
+type Statement interface {
+	Pos() Position
+}
+
+type LetStatement struct {
+	Position Position
+	Name     VarName
+	Index    *Expression
+	Value    *Expression
+}
+
+type IfStatement struct {
+	Position  Position
+	Condition *Expression
+	Then      []Statement
+	Else      []Statement
+	HasElse   bool
+}
+
+type WhileStatement struct {
+	Position  Position
+	Condition *Expression
+	Body      []Statement
+}
+
+type DoStatement struct {
+	Position Position
+	Call     *SubroutineCall
+}
+
+type ReturnStatement struct {
+	Position Position
+	Value    *Expression
+}
+
+type Expression struct {
+	Position  Position
+	Terms     []Term
+	Operators []string
+}
+
+type Term interface {
+	Pos() Position
+}
+
+type IntegerConstant struct {
+	Position Position
+	Value    int
+}
+
+type StringConstant struct {
+	Position Position
+	Value    string
+}
+
+type KeywordConstant struct {
+	Position Position
+	Value    string
+}
+
+// VarTerm is a variable, optionally indexed as an array.
+type VarTerm struct {
+	Position Position
+	Name     string
+	Index    *Expression
+}
+
+// SubroutineCall has an empty Receiver for a call on the current object;
+// otherwise the receiver is a class or variable name.
+type SubroutineCall struct {
+	Position  Position
+	Receiver  string
+	Name      string
+	Arguments []*Expression
+}
+
+type ParenTerm struct {
+	Position   Position
+	Expression *Expression
+}
+
+type UnaryTerm struct {
+	Position Position
+	Operator string
+	Term     Term
+}
+
+func (s *LetStatement) Pos() Position    { return s.Position }
+func (s *IfStatement) Pos() Position     { return s.Position }
+func (s *WhileStatement) Pos() Position  { return s.Position }
+func (s *DoStatement) Pos() Position     { return s.Position }
+func (s *ReturnStatement) Pos() Position { return s.Position }
+
+func (t *IntegerConstant) Pos() Position { return t.Position }
+func (t *StringConstant) Pos() Position  { return t.Position }
+func (t *KeywordConstant) Pos() Position { return t.Position }
+func (t *VarTerm) Pos() Position         { return t.Position }
+func (t *SubroutineCall) Pos() Position  { return t.Position }
+func (t *ParenTerm) Pos() Position       { return t.Position }
+func (t *UnaryTerm) Pos() Position       { return t.Position }
@@ -0,0 +1,209 @@
+package compiler
+
+import "fmt"
+
+// symbolUse counts how a declared variable is used in the class.
+type symbolUse struct {
+	name   VarName
+	kind   string
+	reads  int
+	writes int
+	warned bool
+}
+
+// CheckClass reports undeclared and duplicate names as errors, and locals
+// that shadow class variables, unused variables and locals read before they
+// are assigned as warnings.
+func CheckClass(class *Class) CompileErrors {
+	c := &Checker{
+		classVars:   make(map[string]*symbolUse),
+		subroutines: make(map[string]Position),
+	}
+
+	for _, varDec := range class.VarDecs {
+		for _, name := range varDec.Names {
+			c.declare(c.classVars, name, varDec.Kind)
+		}
+	}
+	for _, subroutine := range class.Subroutines {
+		if previous, ok := c.subroutines[subroutine.Name]; ok {
+			c.errorf(subroutine.Position, false, "subroutine %s is already declared on line %d", subroutine.Name, previous.Line)
+			continue
+		}
+		c.subroutines[subroutine.Name] = subroutine.Position
+	}
+
+	for _, subroutine := range class.Subroutines {
+		c.checkSubroutine(subroutine)
+	}
+
+	for _, varDec := range class.VarDecs {
+		for _, name := range varDec.Names {
+			if use := c.classVars[name.Name]; use.name == name && use.reads == 0 {
+				c.unused(use)
+			}
+		}
+	}
+	return c.errors
+}
+
+func (c *Checker) errorf(position Position, warning bool, format string, args ...any) {
+	c.errors = append(c.errors, &CompileError{
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+		Warning:  warning,
+	})
+}
+
+func (c *Checker) declare(scope map[string]*symbolUse, name VarName, kind string) {
+	if previous, ok := scope[name.Name]; ok {
+		c.errorf(name.Position, false, "%s is already declared on line %d", name.Name, previous.name.Position.Line)
+		return
+	}
+	scope[name.Name] = &symbolUse{name: name, kind: kind}
+}
+
+func (c *Checker) unused(use *symbolUse) {
+	kind := use.kind
+	if kind == "var" {
+		kind = "local"
+	}
+	if use.writes == 0 {
+		c.errorf(use.name.Position, true, "%s %s is never used", kind, use.name.Name)
+	} else {
+		c.errorf(use.name.Position, true, "%s %s is assigned but never read", kind, use.name.Name)
+	}
+}
+
+func (c *Checker) lookup(name string) *symbolUse {
+	if use, ok := c.locals[name]; ok {
+		return use
+	}
+	return c.classVars[name]
+}
+
+func (c *Checker) checkSubroutine(subroutine *Subroutine) {
+	c.locals = make(map[string]*symbolUse)
+	for _, parameter := range subroutine.Parameters {
+		c.declare(c.locals, parameter.Name, "argument")
+	}
+	for _, varDec := range subroutine.VarDecs {
+		for _, name := range varDec.Names {
+			c.declare(c.locals, name, "var")
+		}
+	}
+	for _, use := range c.locals {
+		if classVar, ok := c.classVars[use.name.Name]; ok {
+			c.errorf(use.name.Position, true, "%s shadows the %s declared on line %d", use.name.Name, classVar.kind, classVar.name.Position.Line)
+		}
+	}
+
+	c.statements(subroutine.Statements, make(map[string]bool))
+
+	for _, varDec := range subroutine.VarDecs {
+		for _, name := range varDec.Names {
+			if use := c.locals[name.Name]; use.name == name && use.reads == 0 {
+				c.unused(use)
+			}
+		}
+	}
+	c.locals = nil
+}
+
+// statements walks a block in order. assigned holds the locals that have
+// been assigned on every path so far; an if keeps only what both branches
+// assign and a while body may not run at all, so neither adds to it
+// otherwise.
+func (c *Checker) statements(statements []Statement, assigned map[string]bool) {
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *LetStatement:
+			if s.Index != nil {
+				c.read(s.Name.Name, s.Name.Position, assigned)
+				c.expression(s.Index, assigned)
+			}
+			c.expression(s.Value, assigned)
+			if s.Index == nil {
+				if use := c.lookup(s.Name.Name); use == nil {
+					c.errorf(s.Name.Position, false, "undefined variable %s", s.Name.Name)
+				} else {
+					use.writes++
+					assigned[s.Name.Name] = true
+				}
+			}
+		case *IfStatement:
+			c.expression(s.Condition, assigned)
+			then, otherwise := copySet(assigned), copySet(assigned)
+			c.statements(s.Then, then)
+			c.statements(s.Else, otherwise)
+			for name := range then {
+				if otherwise[name] {
+					assigned[name] = true
+				}
+			}
+		case *WhileStatement:
+			c.expression(s.Condition, assigned)
+			c.statements(s.Body, copySet(assigned))
+		case *DoStatement:
+			c.call(s.Call, assigned)
+		case *ReturnStatement:
+			if s.Value != nil {
+				c.expression(s.Value, assigned)
+			}
+		}
+	}
+}
+
+func (c *Checker) read(name string, position Position, assigned map[string]bool) {
+	use := c.lookup(name)
+	if use == nil {
+		c.errorf(position, false, "undefined variable %s", name)
+		return
+	}
+	use.reads++
+	if use.kind == "var" && !assigned[name] && !use.warned {
+		use.warned = true
+		c.errorf(position, true, "local %s may be read before it is assigned", name)
+	}
+}
+
+func (c *Checker) expression(expression *Expression, assigned map[string]bool) {
+	for _, term := range expression.Terms {
+		c.term(term, assigned)
+	}
+}
+
+func (c *Checker) term(term Term, assigned map[string]bool) {
+	switch t := term.(type) {
+	case *VarTerm:
+		c.read(t.Name, t.Position, assigned)
+		if t.Index != nil {
+			c.expression(t.Index, assigned)
+		}
+	case *SubroutineCall:
+		c.call(t, assigned)
+	case *ParenTerm:
+		c.expression(t.Expression, assigned)
+	case *UnaryTerm:
+		c.term(t.Term, assigned)
+	}
+}
+
+// call reads the receiver when it is a variable; any other receiver is a
+// class name, which the code generator resolves.
+func (c *Checker) call(call *SubroutineCall, assigned map[string]bool) {
+	if call.Receiver != "" && c.lookup(call.Receiver) != nil {
+		c.read(call.Receiver, call.Position, assigned)
+	}
+	for _, argument := range call.Arguments {
+		c.expression(argument, assigned)
+	}
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(set))
+	for name := range set {
+		result[name] = true
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseClass parses the source of a single class in Main.jack.
+func parseClass(t *testing.T, source string) *Class {
+	t.Helper()
+	tokens, err := NewLexer("Main.jack", source, NewTokenizer()).Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	class, err := NewClassParser("Main.jack", tokens).ParseClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return class
+}
+
+// diagnostics lists errors as line:column: message, in position order.
+func diagnostics(errs CompileErrors) []string {
+	var lines []string
+	for _, err := range errs.withSource(nil) {
+		message := err.Message
+		if err.Warning {
+			message = "warning: " + message
+		}
+		lines = append(lines, fmt.Sprintf("%d:%d: %s", err.Position.Line, err.Position.Column, message))
+	}
+	return lines
+}
+
+func TestCheckClass(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name: "clean",
+			source: `class Main {
+    field int n;
+    method int f(int a) {
+        var int b;
+        if (a > 0) { let b = a; } else { let b = 1; }
+        let n = b;
+        return n;
+    }
+}`,
+		},
+		{
+			name: "undeclared",
+			source: `class Main {
+    function int f() {
+        let x = 1;
+        return y + Other.g();
+    }
+}`,
+			want: []string{
+				"3:13: undefined variable x",
+				"4:16: undefined variable y",
+			},
+		},
+		{
+			name: "duplicates",
+			source: `class Main {
+    static int s, s;
+    function void f(int a) {
+        var int a;
+        return;
+    }
+    function void f() {
+        return;
+    }
+}`,
+			want: []string{
+				"2:16: warning: static s is never used",
+				"2:19: s is already declared on line 2",
+				"4:17: a is already declared on line 3",
+				"7:5: subroutine f is already declared on line 3",
+			},
+		},
+		{
+			name: "shadowed",
+			source: `class Main {
+    field int n;
+    method int f() {
+        var int n;
+        let n = 2;
+        return n;
+    }
+}`,
+			want: []string{
+				"2:15: warning: field n is never used",
+				"4:17: warning: n shadows the field declared on line 2",
+			},
+		},
+		{
+			name: "unused",
+			source: `class Main {
+    static int s;
+    function int f() {
+        var int a, b;
+        let b = 1;
+        return 0;
+    }
+}`,
+			want: []string{
+				"2:16: warning: static s is never used",
+				"4:17: warning: local a is never used",
+				"4:20: warning: local b is assigned but never read",
+			},
+		},
+		{
+			name: "read before assigned",
+			source: `class Main {
+    function int f(boolean c) {
+        var int a, b;
+        if (c) { let a = 1; }
+        while (c) { let b = 2; }
+        return a + b + a;
+    }
+}`,
+			want: []string{
+				"6:16: warning: local a may be read before it is assigned",
+				"6:20: warning: local b may be read before it is assigned",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := diagnostics(CheckClass(parseClass(t, test.source)))
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
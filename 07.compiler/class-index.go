@@ -0,0 +1,27 @@
+package compiler
+
+func NewClassIndex() *ClassIndex {
+	return &ClassIndex{
+		classes: make(map[string]map[string]*Subroutine),
+	}
+}
+
+// Add indexes the subroutines of a class, replacing any earlier class of the
+// same name, so project classes take over from the OS signatures.
+func (ci *ClassIndex) Add(class *Class) {
+	subroutines := make(map[string]*Subroutine)
+	for _, subroutine := range class.Subroutines {
+		subroutines[subroutine.Name] = subroutine
+	}
+	ci.classes[class.Name] = subroutines
+}
+
+func (ci *ClassIndex) HasClass(name string) bool {
+	_, ok := ci.classes[name]
+	return ok
+}
+
+func (ci *ClassIndex) Subroutine(className, name string) (*Subroutine, bool) {
+	subroutine, ok := ci.classes[className][name]
+	return subroutine, ok
+}
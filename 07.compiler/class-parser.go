@@ -0,0 +1,516 @@
+package compiler
+
+import "strconv"
+
+// ClassParser is a recursive-descent parser for one class. Each parse
+// method reads exactly the tokens of its grammar rule, with one token of
+// lookahead except for telling a variable from a call inside a term.
+type ClassParser struct {
+	tokens  []Tokenized
+	current int
+	file    string
+}
+
+func NewClassParser(file string, tokens []Tokenized) *ClassParser {
+	return &ClassParser{tokens: tokens, file: file}
+}
+
+func (p *ClassParser) peek(ahead int) Tokenized {
+	if p.current+ahead >= len(p.tokens) {
+		return Tokenized{Type: "EOF", Value: "end of file", Position: p.endPosition()}
+	}
+	return p.tokens[p.current+ahead]
+}
+
+func (p *ClassParser) endPosition() Position {
+	if len(p.tokens) == 0 {
+		return Position{File: p.file, Line: 1, Column: 1}
+	}
+	last := p.tokens[len(p.tokens)-1]
+	end := last.Position
+	end.Column += len(tokenText(last))
+	return end
+}
+
+func (p *ClassParser) next() Tokenized {
+	token := p.peek(0)
+	if p.current < len(p.tokens) {
+		p.current++
+	}
+	return token
+}
+
+func (p *ClassParser) is(values ...string) bool {
+	token := p.peek(0)
+	if token.Type != KEYWORD && token.Type != SYMBOL {
+		return false
+	}
+	return contains(values, token.Value)
+}
+
+func (p *ClassParser) errorf(token Tokenized, expected string) error {
+	return &CompileError{Position: token.Position, Expected: expected, Found: describeToken(token)}
+}
+
+func (p *ClassParser) expect(value string) (Tokenized, error) {
+	if !p.is(value) {
+		return Tokenized{}, p.errorf(p.peek(0), "'"+value+"'")
+	}
+	return p.next(), nil
+}
+
+func (p *ClassParser) expectIdentifier(what string) (VarName, error) {
+	token := p.peek(0)
+	if token.Type != IDENTIFIER {
+		return VarName{}, p.errorf(token, what)
+	}
+	p.next()
+	return VarName{Position: token.Position, Name: token.Value.(string)}, nil
+}
+
+// expectType reads a type; void is accepted only where allowVoid is set.
+func (p *ClassParser) expectType(allowVoid bool) (string, error) {
+	token := p.peek(0)
+	if token.Type == IDENTIFIER || (token.Type == KEYWORD && contains(types, token.Value)) ||
+		(allowVoid && token.Value == "void" && token.Type == KEYWORD) {
+		p.next()
+		return tokenText(token), nil
+	}
+	if allowVoid {
+		return "", p.errorf(token, "a return type")
+	}
+	return "", p.errorf(token, "a type")
+}
+
+func (p *ClassParser) ParseClass() (*Class, error) {
+	class, err := p.parseClass()
+	if err != nil {
+		return nil, err
+	}
+	if p.current < len(p.tokens) {
+		return nil, p.errorf(p.peek(0), "end of file")
+	}
+	return class, nil
+}
+
+// ParseClasses reads several classes from one file, as in the OS
+// signatures.
+func (p *ClassParser) ParseClasses() ([]*Class, error) {
+	var classes []*Class
+	for p.current < len(p.tokens) {
+		class, err := p.parseClass()
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func (p *ClassParser) parseClass() (*Class, error) {
+	start, err := p.expect("class")
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdentifier("a class name")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	class := &Class{Position: start.Position, Name: name.Name}
+	for p.is(classVarDec...) {
+		varDec, err := p.parseClassVarDec()
+		if err != nil {
+			return nil, err
+		}
+		class.VarDecs = append(class.VarDecs, varDec)
+	}
+	for p.is(subroutineDec...) {
+		subroutine, err := p.parseSubroutine()
+		if err != nil {
+			return nil, err
+		}
+		class.Subroutines = append(class.Subroutines, subroutine)
+	}
+
+	if _, err := p.expect("}"); err != nil {
+		return nil, p.errorf(p.peek(0), "a field, subroutine or '}'")
+	}
+	return class, nil
+}
+
+// parseNames reads "name (, name)* ;" as used by field, static and var
+// declarations.
+func (p *ClassParser) parseNames() ([]VarName, error) {
+	var names []VarName
+	for {
+		name, err := p.expectIdentifier("a variable name")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, p.errorf(p.peek(0), "',' or ';'")
+	}
+	return names, nil
+}
+
+func (p *ClassParser) parseClassVarDec() (*ClassVarDec, error) {
+	kind := p.next()
+	varType, err := p.expectType(false)
+	if err != nil {
+		return nil, err
+	}
+	names, err := p.parseNames()
+	if err != nil {
+		return nil, err
+	}
+	return &ClassVarDec{Position: kind.Position, Kind: kind.Value.(string), Type: varType, Names: names}, nil
+}
+
+func (p *ClassParser) parseSubroutine() (*Subroutine, error) {
+	kind := p.next()
+	returnType, err := p.expectType(true)
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdentifier("a subroutine name")
+	if err != nil {
+		return nil, err
+	}
+	subroutine := &Subroutine{
+		Position:   kind.Position,
+		Kind:       kind.Value.(string),
+		ReturnType: returnType,
+		Name:       name.Name,
+	}
+
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		if len(subroutine.Parameters) > 0 {
+			if _, err := p.expect(","); err != nil {
+				return nil, p.errorf(p.peek(0), "',' or ')'")
+			}
+		}
+		start := p.peek(0)
+		paramType, err := p.expectType(false)
+		if err != nil {
+			return nil, err
+		}
+		paramName, err := p.expectIdentifier("a parameter name")
+		if err != nil {
+			return nil, err
+		}
+		subroutine.Parameters = append(subroutine.Parameters, &Parameter{Position: start.Position, Type: paramType, Name: paramName})
+	}
+	p.next()
+
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.is("var") {
+		start := p.next()
+		varType, err := p.expectType(false)
+		if err != nil {
+			return nil, err
+		}
+		names, err := p.parseNames()
+		if err != nil {
+			return nil, err
+		}
+		subroutine.VarDecs = append(subroutine.VarDecs, &VarDec{Position: start.Position, Type: varType, Names: names})
+	}
+
+	subroutine.Statements, err = p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("}"); err != nil {
+		return nil, p.errorf(p.peek(0), "a statement or '}'")
+	}
+	return subroutine, nil
+}
+
+func (p *ClassParser) parseStatements() ([]Statement, error) {
+	var result []Statement
+	for p.is(statements...) {
+		var statement Statement
+		var err error
+
+		switch p.peek(0).Value {
+		case "let":
+			statement, err = p.parseLet()
+		case "if":
+			statement, err = p.parseIf()
+		case "while":
+			statement, err = p.parseWhile()
+		case "do":
+			statement, err = p.parseDo()
+		case "return":
+			statement, err = p.parseReturn()
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, statement)
+	}
+	return result, nil
+}
+
+func (p *ClassParser) parseLet() (Statement, error) {
+	start := p.next()
+	name, err := p.expectIdentifier("a variable name")
+	if err != nil {
+		return nil, err
+	}
+	statement := &LetStatement{Position: start.Position, Name: name}
+
+	if p.is("[") {
+		p.next()
+		if statement.Index, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if statement.Value, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// parseCondition reads "( expression ) { statements }" shared by if and
+// while.
+func (p *ClassParser) parseCondition() (*Expression, []Statement, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, nil, err
+	}
+	condition, err := p.parseExpression()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, nil, err
+	}
+	body, err := p.parseBlock()
+	return condition, body, err
+}
+
+func (p *ClassParser) parseBlock() ([]Statement, error) {
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	body, err := p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("}"); err != nil {
+		return nil, p.errorf(p.peek(0), "a statement or '}'")
+	}
+	return body, nil
+}
+
+func (p *ClassParser) parseIf() (Statement, error) {
+	start := p.next()
+	condition, then, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	statement := &IfStatement{Position: start.Position, Condition: condition, Then: then}
+
+	if p.is("else") {
+		p.next()
+		statement.HasElse = true
+		if statement.Else, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
+	}
+	return statement, nil
+}
+
+func (p *ClassParser) parseWhile() (Statement, error) {
+	start := p.next()
+	condition, body, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	return &WhileStatement{Position: start.Position, Condition: condition, Body: body}, nil
+}
+
+func (p *ClassParser) parseDo() (Statement, error) {
+	start := p.next()
+	name, err := p.expectIdentifier("a subroutine call")
+	if err != nil {
+		return nil, err
+	}
+	call, err := p.parseCall(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return &DoStatement{Position: start.Position, Call: call}, nil
+}
+
+func (p *ClassParser) parseReturn() (Statement, error) {
+	start := p.next()
+	statement := &ReturnStatement{Position: start.Position}
+	if !p.is(";") {
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		statement.Value = value
+	}
+	if _, err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+func (p *ClassParser) parseExpression() (*Expression, error) {
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	expression := &Expression{Position: first.Pos(), Terms: []Term{first}}
+
+	for p.is(operands...) {
+		operator := p.next()
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		expression.Operators = append(expression.Operators, operator.Value.(string))
+		expression.Terms = append(expression.Terms, term)
+	}
+	return expression, nil
+}
+
+func (p *ClassParser) parseTerm() (Term, error) {
+	token := p.peek(0)
+
+	switch token.Type {
+	case INT_CONST:
+		p.next()
+		return &IntegerConstant{Position: token.Position, Value: token.Value.(int)}, nil
+	case STRING_CONST:
+		p.next()
+		return &StringConstant{Position: token.Position, Value: token.Value.(string)}, nil
+	case KEYWORD:
+		if contains(keywordConstants, token.Value) {
+			p.next()
+			return &KeywordConstant{Position: token.Position, Value: token.Value.(string)}, nil
+		}
+	case SYMBOL:
+		if p.is(unaryOperands...) {
+			p.next()
+			term, err := p.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			return &UnaryTerm{Position: token.Position, Operator: token.Value.(string), Term: term}, nil
+		}
+		if p.is("(") {
+			p.next()
+			expression, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return &ParenTerm{Position: token.Position, Expression: expression}, nil
+		}
+	case IDENTIFIER:
+		name, _ := p.expectIdentifier("")
+		next := p.peek(0)
+		if next.Type == SYMBOL && (next.Value == "(" || next.Value == ".") {
+			return p.parseCall(name)
+		}
+		term := &VarTerm{Position: name.Position, Name: name.Name}
+		if p.is("[") {
+			p.next()
+			index, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			term.Index = index
+		}
+		return term, nil
+	}
+	return nil, p.errorf(token, "an expression")
+}
+
+// parseCall reads the rest of a call whose first name has been read: either
+// "name(args)" or "receiver.name(args)".
+func (p *ClassParser) parseCall(first VarName) (*SubroutineCall, error) {
+	call := &SubroutineCall{Position: first.Position, Name: first.Name}
+	if p.is(".") {
+		p.next()
+		name, err := p.expectIdentifier("a subroutine name")
+		if err != nil {
+			return nil, err
+		}
+		call.Receiver, call.Name = first.Name, name.Name
+	}
+
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		if len(call.Arguments) > 0 {
+			if _, err := p.expect(","); err != nil {
+				return nil, p.errorf(p.peek(0), "',' or ')'")
+			}
+		}
+		argument, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.Arguments = append(call.Arguments, argument)
+	}
+	p.next()
+	return call, nil
+}
+
+func tokenText(token Tokenized) string {
+	switch value := token.Value.(type) {
+	case int:
+		return strconv.Itoa(value)
+	case string:
+		if token.Type == STRING_CONST {
+			return `"` + value + `"`
+		}
+		return value
+	}
+	return ""
+}
+
+func describeToken(token Tokenized) string {
+	if token.Type == "EOF" {
+		return "end of file"
+	}
+	return "'" + tokenText(token) + "'"
+}
@@ -0,0 +1,7 @@
+package main
+
+import compiler "nand2tetris/07.compiler"
+
+func main() {
+	compiler.Main()
+}
@@ -1,4 +1,4 @@
-package main
+package compiler
 
 import (
 	"fmt"
@@ -12,35 +12,28 @@ func NewCodeWriter() *CodeWriter {
 	}
 }
 
-func (cw *CodeWriter) WriteFunction(declaration []Tokenized, classTable []Table, parameters [][]Tokenized, className string) string {
-	functionType := declaration[0].Value
-	paramsLength := 0
-
-	for _, param := range parameters {
-		commaIndex := findIndex(param, ",")
-		if commaIndex != -1 {
-			vars := filterTokens(param[2:len(param)-1], ",")
-			paramsLength += len(vars)
-		} else {
-			paramsLength++
-		}
+// WriteFunction writes the function header; a constructor allocates one word
+// per field of its class, a method sets this from its first argument.
+func (cw *CodeWriter) WriteFunction(subroutine *Subroutine, fields int, className string) string {
+	locals := 0
+	for _, varDec := range subroutine.VarDecs {
+		locals += len(varDec.Names)
 	}
+	functionDec := fmt.Sprintf("function %s.%s %d", className, subroutine.Name, locals)
 
-	if functionType == "constructor" {
-		functionDec := fmt.Sprintf("function %s.%s %d", declaration[1].Value, declaration[2].Value, paramsLength)
-		pushConstant := fmt.Sprintf("push constant %d", len(classTable))
+	switch subroutine.Kind {
+	case "constructor":
+		pushConstant := fmt.Sprintf("push constant %d", fields)
 		callMemory := "call Memory.alloc 1"
 		popPointer := "pop pointer 0"
 
 		return fmt.Sprintf("%s\n%s\n%s\n%s", functionDec, pushConstant, callMemory, popPointer)
-	} else if functionType == "method" {
-		functionDec := fmt.Sprintf("function %s.%s %d", className, declaration[2].Value, paramsLength)
+	case "method":
 		argument := "push argument 0"
 		popPointer := "pop pointer 0"
 
 		return fmt.Sprintf("%s\n%s\n%s", functionDec, argument, popPointer)
-	} else {
-		functionDec := fmt.Sprintf("function %s.%s %d", className, declaration[2].Value, paramsLength)
+	default:
 		return functionDec
 	}
 }
@@ -1,21 +1,19 @@
-package main
+package compiler
 
 import (
 	"fmt"
-	"log"
 	"os"
-	"slices"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
 func NewCompilationEngine(tokenizedFiles []TokenizedFile) *CompilationEngine {
 	return &CompilationEngine{
-		tokenizedFiles:   tokenizedFiles,
-		divider:          NewDivider(),
-		symbolTable:      NewSymbolTable(),
-		codeWriter:       NewCodeWriter(),
-		className:        "",
+		tokenizedFiles: tokenizedFiles,
+		symbolTable:    NewSymbolTable(),
+		codeWriter:     NewCodeWriter(),
+		className:      "",
 	}
 }
 
@@ -23,391 +21,294 @@ func (ce *CompilationEngine) UpdateFiles(tokenizedFiles []TokenizedFile) {
 	ce.tokenizedFiles = tokenizedFiles
 }
 
-func (ce *CompilationEngine) Compile() error {
+// Compile parses and compiles every file. A file with a syntax error is
+// left out, but the others are still checked so all of their errors are
+// reported in one go. The output is only usable when the returned errors
+// hold no errors: Analyzer.Compile writes nothing if any file failed.
+//
+// Every class is parsed and indexed, after the OS classes, before any code
+// is generated so calls can be resolved across the whole project. With type
+// rules set, each class is also type checked against that index.
+func (ce *CompilationEngine) Compile(osClasses []*Class) ([]OutputFile, CompileErrors) {
+	var outputFiles []OutputFile
+	var classes []*Class
+	var filenames []string
+	ce.errors = nil
+	ce.layouts = nil
+
+	ce.classIndex = NewClassIndex()
+	for _, class := range osClasses {
+		ce.classIndex.Add(class)
+	}
+
 	for _, file := range ce.tokenizedFiles {
-		ce.className = strings.TrimSuffix(file.Filename, ".jack")
-		vmCode := ce.CompileClass(file.Content)
-		
-		vmFilename := fmt.Sprintf("%s.vm", ce.className)
-		err := os.WriteFile(vmFilename, []byte(vmCode), 0644)
+		class, err := NewClassParser(file.Filename, file.Content).ParseClass()
 		if err != nil {
-			return err
+			ce.errors = append(ce.errors, err.(*CompileError))
+			ce.Unparsed(file.Filename)
+			continue
+		}
+		ce.classIndex.Add(class)
+
+		checked := CheckClass(class)
+		ce.errors = append(ce.errors, checked...)
+		if checked.HasErrors() {
+			continue
+		}
+		classes = append(classes, class)
+		filenames = append(filenames, file.Filename)
+	}
+
+	for i, class := range classes {
+		if ce.typeRules != nil {
+			checked := CheckTypes(class, ce.classIndex, ce.typeRules)
+			ce.errors = append(ce.errors, checked...)
+			if checked.HasErrors() {
+				continue
+			}
 		}
+
+		name := strings.TrimSuffix(filenames[i], ".jack")
+		ce.className = filepath.Base(name)
+		outputFiles = append(outputFiles, OutputFile{
+			Filename: name + ".vm",
+			Code:     ce.CompileClass(class),
+		})
 		ce.className = ""
 	}
-	return nil
+	return outputFiles, ce.errors
+}
+
+// Unparsed marks the class of a file that did not lex or parse, so calls to
+// it are not reported as calls to an unknown class as well.
+func (ce *CompilationEngine) Unparsed(filename string) {
+	if ce.unparsed == nil {
+		ce.unparsed = make(map[string]bool)
+	}
+	ce.unparsed[strings.TrimSuffix(filepath.Base(filename), ".jack")] = true
 }
 
-func (ce *CompilationEngine) CompileClass(content []Tokenized) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("compileClass error:", r)
+// WriteFiles writes each file next to its source, or into directory when it
+// is set.
+func (ce *CompilationEngine) WriteFiles(outputFiles []OutputFile, directory string) error {
+	if directory != "" {
+		if err := os.MkdirAll(directory, 0755); err != nil {
+			return err
 		}
-	}()
+	}
 
-	var vmCode string
-	curlyBracketIndex := findIndex(content, "{")
-	if curlyBracketIndex == -1 {
-		fmt.Println("No opening curly bracket found")
-		return ""
+	for _, file := range outputFiles {
+		path := file.Filename
+		if directory != "" {
+			path = filepath.Join(directory, filepath.Base(path))
+		}
+		if err := os.WriteFile(path, []byte(file.Code), 0644); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	classBody := content[curlyBracketIndex+1 : len(content)-1]
+func (ce *CompilationEngine) errorf(position Position, format string, args ...any) {
+	ce.errors = append(ce.errors, &CompileError{Position: position, Message: fmt.Sprintf(format, args...)})
+}
 
-	classVarDecs := ce.divider.DivideClassVarDecs(classBody)
-	ce.symbolTable.CreateClassTable(classVarDecs)
+func (ce *CompilationEngine) CompileClass(class *Class) string {
+	var vmCode string
 
-	subroutines := ce.divider.DivideSubroutines(classBody)
-	ce.symbolTable.CreateMethods(subroutines)
+	ce.symbolTable.CreateClassTable(class.VarDecs)
+	ce.symbolTable.CreateMethods(class.Subroutines)
+	ce.layouts = append(ce.layouts, ce.symbolTable.Layout(ce.className))
 
-	for _, subroutine := range subroutines {
+	for _, subroutine := range class.Subroutines {
+		ce.subroutineKind = subroutine.Kind
 		vmCode += ce.CompileSubroutine(subroutine)
 	}
 
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileSubroutine(subroutine []Tokenized) string {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("compileSubroutine error: %v", r)
-		}
-	}()
- 
+func (ce *CompilationEngine) CompileSubroutine(subroutine *Subroutine) string {
 	ce.symbolTable.ResetSubroutineTable()
- 
-	subroutineBody := ce.divider.DivideSubroutineBody(subroutine)
-	varDec := ce.divider.DivideVarDecs(subroutineBody)
+	ce.symbolTable.CreateSubroutineTable(subroutine, ce.className)
+
 	functionCode := ce.codeWriter.WriteFunction(
-		subroutine[:3],
-		ce.symbolTable.ClassTable,
-		varDec,
+		subroutine,
+		ce.symbolTable.Count("field"),
 		ce.className,
 	)
- 
-	subroutineType := subroutine[0].Value.(string)
-	parameters := ce.divider.DivideParameterList(subroutine)
-	body := ce.CompileSubroutineBody(
-		subroutineBody,
-		parameters,
-		subroutineType,
-	)
- 
+	body := ce.CompileStatements(subroutine.Statements)
+
 	vmCode := fmt.Sprintf(
-		"// function %s.%s.%s\n%s\n\n%s", 
-		subroutine[0].Value, 
-		subroutine[1].Value, 
-		subroutine[2].Value, 
-		functionCode, 
+		"// function %s.%s.%s\n%s\n\n%s",
+		subroutine.Kind,
+		subroutine.ReturnType,
+		subroutine.Name,
+		functionCode,
 		body,
 	)
- 
-	return vmCode
-}
 
-func (ce *CompilationEngine) CompileSubroutineBody(
-	content []Tokenized,
-	parameters [][]Tokenized,
-	subroutineType string,
-) string {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("compileSubroutineBody error: %v", r)
-		}
-	}()
- 
-	varDec := ce.divider.DivideVarDecs(content)
-	
-	combinedVars := append(parameters, varDec...)
-	
-	ce.symbolTable.CreateSubroutineTable(
-		combinedVars,
-		subroutineType,
-		ce.className,
-	)
- 
-	statements := ce.divider.DivideStatements(content)
-	
-	var vmCode string
-	if len(statements) > 0 {
-		vmCode = ce.CompileStatements(statements, subroutineType)
-	}
- 
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileStatements(
-	statements [][]Tokenized,
-	subroutineType string,
- ) string {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("compileStatements error: %v", r)
-		}
-	}()
- 
+func (ce *CompilationEngine) CompileStatements(statements []Statement) string {
 	var vmCode strings.Builder
- 
+
 	for _, statement := range statements {
-		typ := statement[0].Value
-		switch typ {
-		case "let":
-			codeLet := ce.CompileLet(statement)
-			vmCode.WriteString(fmt.Sprintf("// let %s\n%s\n\n", statement[1].Value, codeLet))
- 
-		case "if":
-			codeIf := ce.CompileIf(statement, subroutineType)
-			vmCode.WriteString("// if\n" + codeIf + "\n\n")
- 
-		case "while":
-			codeWhile := ce.CompileWhile(statement, subroutineType)
-			vmCode.WriteString("// while\n" + codeWhile + "\n\n")
- 
-		case "do":
-			codeDo := ce.CompileDo(statement, subroutineType)
-			vmCode.WriteString("// do\n" + codeDo + "\n\n")
- 
-		case "return":
-			codeReturn := ce.CompileReturn(statement)
-			vmCode.WriteString("// return\n" + codeReturn + "\n\n")
+		switch s := statement.(type) {
+		case *LetStatement:
+			vmCode.WriteString(fmt.Sprintf("// let %s\n%s\n", s.Name.Name, ce.CompileLet(s)))
+		case *IfStatement:
+			vmCode.WriteString("// if\n" + ce.CompileIf(s) + "\n")
+		case *WhileStatement:
+			vmCode.WriteString("// while\n" + ce.CompileWhile(s) + "\n")
+		case *DoStatement:
+			vmCode.WriteString("// do\n" + ce.CompileDo(s) + "\n")
+		case *ReturnStatement:
+			vmCode.WriteString("// return\n" + ce.CompileReturn(s) + "\n")
 		}
 	}
- 
+
 	return vmCode.String()
 }
 
-func (ce *CompilationEngine) CompileLet(content []Tokenized) string {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("compileLet error: %v", r)
-		}
-	}()
- 
-	var vmCode strings.Builder
- 
-	declarationEnd := -1
-	for i, item := range content {
-		if item.Value == "=" {
-			declarationEnd = i
-			break
-		}
-	}
-	declaration := content[:declarationEnd]
- 
-	isArray := slices.ContainsFunc(declaration, func(token Tokenized) bool {
-		return token.Value == "["
-	})
- 
-	commonTable := append(ce.symbolTable.ClassTable, ce.symbolTable.SubroutineTable...)
- 
-	name := content[1].Value.(string)
-	popOptions := struct {
-		kind  string
-		index int
-	}{
-		kind:  "",
-		index: 0,
+// variable returns the segment and index of a variable.
+func (ce *CompilationEngine) variable(name string, position Position) (string, string) {
+	entry, ok := ce.symbolTable.Lookup(name)
+	if !ok {
+		ce.errorf(position, "undefined variable %s", name)
+		return "", ""
 	}
- 
-	for _, item := range commonTable {
-		if item.Name == name {
-			popOptions.kind = item.Kind
-			popOptions.index = item.Index
-			break
-		}
-	}
- 
-	for i := 0; i < len(content); i++ {
-		token := content[i]
-		switch token.Type {
-		case "SYMBOL":
-			if token.Value == "=" {
-				semiColonIndex := -1
-				for j := i; j < len(content); j++ {
-					if content[j].Value == ";" {
-						semiColonIndex = j
-						break
-					}
-				}
-				expression := ce.CompileExpression(content[i+1:semiColonIndex], "let")
-				vmCode.WriteString(expression + "\n")
-				i = semiColonIndex - 1
-			} else if token.Value == "[" {
-				closingBracketIndex := findClosingBracket(content, i, "[", "]")
-				
-				namePush := ce.codeWriter.WritePush(
-					ce.codeWriter.TransformKind(popOptions.kind),
-					strconv.Itoa(popOptions.index),
-				)
-				expression := ce.CompileExpression(content[i+1:closingBracketIndex], "let")
-				
-				vmCode.WriteString(fmt.Sprintf("%s\n%s\nadd\n", expression, namePush))
-				i = closingBracketIndex
-			}
-		}
-	}
- 
-	if isArray {
-		vmCode.WriteString(ce.codeWriter.WritePop("temp", "0") + "\n")
-	}
- 
-	if popOptions.kind != "" {
-		kind := ce.codeWriter.TransformKind(popOptions.kind)
-		code := ce.codeWriter.WritePop(kind, strconv.Itoa(popOptions.index))
-		vmCode.WriteString(code + "\n")
-	}
- 
-	if isArray {
-		vmCode.WriteString(ce.codeWriter.WritePush("temp", "0") + "\n")
-		vmCode.WriteString(ce.codeWriter.WritePop("that", "0") + "\n")
+	return ce.codeWriter.TransformKind(entry.Kind), strconv.Itoa(entry.Index)
+}
+
+func (ce *CompilationEngine) CompileLet(statement *LetStatement) string {
+	var vmCode strings.Builder
+	kind, index := ce.variable(statement.Name.Name, statement.Name.Position)
+
+	if statement.Index == nil {
+		vmCode.WriteString(ce.CompileExpression(statement.Value))
+		vmCode.WriteString(ce.codeWriter.WritePop(kind, index) + "\n")
+		return vmCode.String()
 	}
- 
+
+	vmCode.WriteString(ce.codeWriter.WritePush(kind, index) + "\n")
+	vmCode.WriteString(ce.CompileExpression(statement.Index))
+	vmCode.WriteString("add\n")
+	vmCode.WriteString(ce.CompileExpression(statement.Value))
+	vmCode.WriteString(ce.codeWriter.WritePop("temp", "0") + "\n")
+	vmCode.WriteString(ce.codeWriter.WritePop("pointer", "1") + "\n")
+	vmCode.WriteString(ce.codeWriter.WritePush("temp", "0") + "\n")
+	vmCode.WriteString(ce.codeWriter.WritePop("that", "0") + "\n")
 	return vmCode.String()
 }
 
-func (ce *CompilationEngine) CompileIf(content []Tokenized, subroutineType string) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("CompileIf error:", r)
-		}
-	}()
-
+func (ce *CompilationEngine) CompileIf(statement *IfStatement) string {
 	var vmCode string
 
-	expression := ce.divider.DivideExpression(content)
 	labels := ce.codeWriter.WriteIfLabels()
+	vmCode += ce.CompileExpression(statement.Condition)
 
-	if len(expression) > 0 {
-		exp := ce.CompileExpression(expression, "if")
-		vmCode += exp + "\n"
-	}
-
-	divideIfStatements := ce.divider.DivideIfStatements(content)
-	ifStatements := divideIfStatements.If
-	elseStatements := divideIfStatements.Else
-
-	compiledIfStatements := ce.CompileStatements(ifStatements, subroutineType)
 	vmCode += labels["if"] + "\n" + labels["goto"] + "\n"
-	vmCode += labels["labelIf"] + "\n" + compiledIfStatements
+	vmCode += labels["labelIf"] + "\n" + ce.CompileStatements(statement.Then)
 
-	elseCheck := false
-	for _, token := range content {
-		if token.Value == "else" {
-			elseCheck = true
-			break
-		}
-	}
-
-	if elseCheck {
+	if statement.HasElse {
 		vmCode += labels["gotoEnd"] + "\n"
 		vmCode += labels["labelElse"] + "\n"
-		compiledElseStatements := ce.CompileStatements(elseStatements, subroutineType)
-		vmCode += compiledElseStatements
+		vmCode += ce.CompileStatements(statement.Else)
 		vmCode += labels["labelEnd"] + "\n"
 	} else {
-		vmCode += labels["labelEnd"] + "\n"
+		vmCode += labels["labelElse"] + "\n"
 	}
 
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileWhile(content []Tokenized, subroutineType string) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("CompileWhile error:", r)
-		}
-	}()
-
+func (ce *CompilationEngine) CompileWhile(statement *WhileStatement) string {
 	var vmCode string
 
 	labels := ce.codeWriter.WriteWhileLabels()
 	vmCode += labels["labelStart"] + "\n"
-
-	expression := ce.divider.DivideExpression(content)
-	if len(expression) > 0 {
-		exp := ce.CompileExpression(expression, "while")
-		vmCode += exp + "\nnot\n"
-	}
-
+	vmCode += ce.CompileExpression(statement.Condition) + "not\n"
 	vmCode += labels["if"] + "\n"
-
-	statements := ce.divider.DivideWhileStatements(content)
-	if len(statements) > 0 {
-		compiledStatements := ce.CompileStatements(statements, subroutineType)
-		vmCode += compiledStatements
-	}
-
+	vmCode += ce.CompileStatements(statement.Body)
 	vmCode += labels["goto"] + "\n" + labels["labelEnd"] + "\n"
 
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileDo(content []Tokenized, subroutineType string) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("CompileDo error:", r)
-		}
-	}()
+func (ce *CompilationEngine) CompileDo(statement *DoStatement) string {
+	vmCode := ce.CompileCall(statement.Call)
+	vmCode += ce.codeWriter.WritePop("temp", "0") + "\n"
+	return vmCode
+}
 
+// CompileCall resolves a call through the class index. A call without a
+// receiver is to a subroutine of the current class, a receiver that is a
+// variable makes it a method of the variable's class, and any other
+// receiver names a class. Methods get their object pushed before the
+// arguments.
+func (ce *CompilationEngine) CompileCall(call *SubroutineCall) string {
 	var vmCode string
-	var caller, name string
+	var caller string
 	length := 0
 
-	expressionList := ce.divider.DivideExpression(content)
-	commonTable := append(ce.symbolTable.ClassTable, ce.symbolTable.SubroutineTable...)
-
-	var method *Table
-	for i := range commonTable {
-		if commonTable[i].Name == content[1].Value {
-			method = &commonTable[i]
-			break
+	if call.Receiver == "" {
+		caller = ce.className
+		subroutine := ce.resolve(call, caller)
+		if subroutine == nil || subroutine.Kind == "method" {
+			if ce.subroutineKind == "function" {
+				ce.errorf(call.Position, "method %s.%s called from a function", caller, call.Name)
+			}
+			vmCode += ce.codeWriter.WritePush("pointer", "0") + "\n"
+			length++
 		}
-	}
-
-	if method != nil && method.Kind == "field" {
-		vmCode += ce.codeWriter.WritePush("this", "0") + "\n"
-		caller = content[1].Value.(string)
-		name = content[3].Value.(string)
+	} else if entry, ok := ce.symbolTable.Lookup(call.Receiver); ok {
+		kind, index := ce.variable(call.Receiver, call.Position)
+		vmCode += ce.codeWriter.WritePush(kind, index) + "\n"
+		caller = entry.Type
 		length++
+		if !contains(types, caller) {
+			if subroutine := ce.resolve(call, caller); subroutine != nil && subroutine.Kind != "method" {
+				ce.errorf(call.Position, "%s.%s is a %s, not a method of %s", caller, call.Name, subroutine.Kind, call.Receiver)
+			}
+		}
 	} else {
-		if contains(operatingSystem, content[1].Value) {
-			caller = content[1].Value.(string)
-			name = content[3].Value.(string)
-		} else {
-			vmCode += ce.codeWriter.WritePush("pointer", "0") + "\n"
-			caller = ce.className
-			name = content[1].Value.(string)
-			length++
+		caller = call.Receiver
+		if subroutine := ce.resolve(call, caller); subroutine != nil && subroutine.Kind == "method" {
+			ce.errorf(call.Position, "method %s.%s called without an object", caller, call.Name)
 		}
 	}
 
-	if len(expressionList) > 0 {
-		compliseExpressionList := ce.CompileExpressionList(expressionList)
-		
-		expressionCode := compliseExpressionList.Code
-		args := compliseExpressionList.Args
-
-		vmCode += expressionCode
-		length += args
-	}
+	expressionList := ce.CompileExpressionList(call.Arguments)
+	vmCode += expressionList.Code
+	length += expressionList.Args
 
-	vmCode += ce.codeWriter.WriteCall(fmt.Sprintf("%s.%s", caller, name), length) + "\n"
-	vmCode += ce.codeWriter.WritePop("temp", "0") + "\n"
+	vmCode += ce.codeWriter.WriteCall(fmt.Sprintf("%s.%s", caller, call.Name), length) + "\n"
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileReturn(content []Tokenized) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("CompileReturn error:", r)
+// resolve looks up the subroutine a call refers to and reports an unknown
+// class or subroutine.
+func (ce *CompilationEngine) resolve(call *SubroutineCall, className string) *Subroutine {
+	if !ce.classIndex.HasClass(className) {
+		if !ce.unparsed[className] {
+			ce.errorf(call.Position, "unknown class %s", className)
 		}
-	}()
+		return nil
+	}
+	subroutine, ok := ce.classIndex.Subroutine(className, call.Name)
+	if !ok {
+		ce.errorf(call.Position, "unknown subroutine %s.%s", className, call.Name)
+		return nil
+	}
+	return subroutine
+}
 
+func (ce *CompilationEngine) CompileReturn(statement *ReturnStatement) string {
 	var vmCode string
-	expressions := content[1 : len(content)-1]
-	if len(expressions) > 0 {
-		expression := ce.CompileExpression(expressions, "return")
-		vmCode += expression + "\nreturn\n"
+	if statement.Value != nil {
+		vmCode += ce.CompileExpression(statement.Value) + "return\n"
 	} else {
 		vmCode += "push constant 0\nreturn\n"
 	}
@@ -415,164 +316,86 @@ func (ce *CompilationEngine) CompileReturn(content []Tokenized) string {
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileExpression(content []Tokenized, from string) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("compileExpression error:", r)
-		}
-	}()
-
-	var vmCode string
-	var terms [][]Tokenized
-
-	if from == "let" {
-		terms = ce.divider.DivideVarTerms(content)
-	} else {
-		terms = ce.divider.DivideTerms(content)
-	}
-
-	terms = ce.divider.ShuffleTerms(terms)
-
-	for i := 0; i < len(terms); i++ {
-		term := terms[i]
-
-		if len(term) > 0 && len(terms) == 2 {
-			if unaryOperands := []string{"-", "~"}; contains(unaryOperands, terms[1][0].Value) {
-				vmCode += ce.CompileTerm(terms[0]) + "\n"
-				switch terms[1][0].Value {
-				case "-":
-					vmCode += "neg\n"
-				case "~":
-					vmCode += "not\n"
-				}
-				break
-			}
-		} else if len(term) > 0 && contains([]string{"<", ">", "&", "|", "=", "+", "-", "*", "/"}, term[0].Value) {
-			value := term[0].Value
-			switch value {
-			case "<":
-				vmCode += "lt\n"
-			case ">":
-				vmCode += "gt\n"
-			case "&":
-				vmCode += "and\n"
-			case "|":
-				vmCode += "or\n"
-			case "=":
-				vmCode += "eq\n"
-			case "+":
-				vmCode += "add\n"
-			case "-":
-				vmCode += "sub\n"
-			case "*":
-				vmCode += "call Math.multiply 2\n"
-			case "/":
-				vmCode += "call Math.divide 2\n"
-			}
-		} else if len(term) > 0 && term[0].Value == "(" {
-			vmCode += ce.CompileTerm(term) + "\n"
-		} else {
-			vmCode += ce.CompileTerm(term) + "\n"
+// CompileExpression evaluates the terms from left to right; Jack has no
+// operator precedence.
+func (ce *CompilationEngine) CompileExpression(expression *Expression) string {
+	vmCode := ce.CompileTerm(expression.Terms[0])
+	for i, operator := range expression.Operators {
+		vmCode += ce.CompileTerm(expression.Terms[i+1])
+		switch operator {
+		case "<":
+			vmCode += "lt\n"
+		case ">":
+			vmCode += "gt\n"
+		case "&":
+			vmCode += "and\n"
+		case "|":
+			vmCode += "or\n"
+		case "=":
+			vmCode += "eq\n"
+		case "+":
+			vmCode += "add\n"
+		case "-":
+			vmCode += "sub\n"
+		case "*":
+			vmCode += "call Math.multiply 2\n"
+		case "/":
+			vmCode += "call Math.divide 2\n"
 		}
 	}
 
 	return vmCode
 }
 
-func (ce *CompilationEngine) CompileTerm(content []Tokenized) string {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("compileTerm error:", r)
+func (ce *CompilationEngine) CompileTerm(term Term) string {
+	switch t := term.(type) {
+	case *IntegerConstant:
+		return ce.codeWriter.WritePush("constant", strconv.Itoa(t.Value)) + "\n"
+	case *StringConstant:
+		return ce.codeWriter.WriteString(t.Value)
+	case *KeywordConstant:
+		switch t.Value {
+		case "true":
+			return "push constant 0\nnot\n"
+		case "this":
+			return ce.codeWriter.WritePush("pointer", "0") + "\n"
+		default:
+			return "push constant 0\n"
 		}
-	}()
-
-	var vmCode string
-	for i := 0; i < len(content); i++ {
-		token := content[i]
-		switch token.Value {
-		case "IDENTIFIER":
-			if i+1 < len(content) && content[i+1].Value == "(" {
-				closingIndex := -1
-				bracketCount := 0
-				for j := i + 1; j < len(content); j++ {
-					if content[j].Value == "(" {
-						bracketCount++
-					} else if content[j].Value == ")" {
-						bracketCount--
-						if bracketCount == 0 {
-							closingIndex = j
-							break
-						}
-					}
-				}
-
-				exprList := ce.CompileExpressionList(content[i+2 : closingIndex])
-				vmCode += exprList.Code
-				vmCode += ce.codeWriter.WriteCall(content[i-2].Value.(string) + "." + token.Value.(string), exprList.Args)
-				i = closingIndex
-			} else {
-				commonTable := append(ce.symbolTable.ClassTable, ce.symbolTable.SubroutineTable...)
-
-				tableIndex := customFindIndex(commonTable, func(entry Table) bool {
-					return entry.Name == token.Value
-				})
-
-				if tableIndex != -1 {
-					table := commonTable[tableIndex]
-					kind := ce.codeWriter.TransformKind(table.Kind)
-					vmCode += ce.codeWriter.WritePush(kind, strconv.Itoa(table.Index)) + "\n"
-				}
-			}
-		case "STRING_CONST":
-			vmCode += ce.codeWriter.WriteString(token.Value.(string))
-		case "INT_CONST":
-			vmCode += ce.codeWriter.WritePush("constant", token.Value.(string))
-		case "SYMBOL":
-			if token.Value == "[" {
-				closingIndex := -1
-				bracketCount := 1
-				for j := i + 1; j < len(content); j++ {
-					if content[j].Value == "[" {
-						bracketCount++
-					} else if content[j].Value == "]" {
-						bracketCount--
-						if bracketCount == 0 {
-							closingIndex = j
-							break
-						}
-					}
-				}
-
-				expr := ce.CompileExpression(content[i+1:closingIndex], "term")
-				vmCode += expr + "\nadd\npop pointer 1\npush that 0"
-				i = closingIndex
-			}
+	case *VarTerm:
+		kind, index := ce.variable(t.Name, t.Position)
+		vmCode := ce.codeWriter.WritePush(kind, index) + "\n"
+		if t.Index != nil {
+			vmCode += ce.CompileExpression(t.Index) + "add\npop pointer 1\npush that 0\n"
 		}
+		return vmCode
+	case *SubroutineCall:
+		return ce.CompileCall(t)
+	case *ParenTerm:
+		return ce.CompileExpression(t.Expression)
+	case *UnaryTerm:
+		vmCode := ce.CompileTerm(t.Term)
+		if t.Operator == "-" {
+			return vmCode + "neg\n"
+		}
+		return vmCode + "not\n"
 	}
 
-	return vmCode
+	return ""
 }
 
-func (ce *CompilationEngine) CompileExpressionList(content []Tokenized) (result struct{ Code string; Args int }) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("compileExpressionList error:", r)
-			result = struct{ Code string; Args int }{"", 0}
-		}
-	}()
-
+func (ce *CompilationEngine) CompileExpressionList(expressions []*Expression) (result struct {
+	Code string
+	Args int
+}) {
 	var vmCode string
-	var counter int
-	list := ce.divider.DivideExpressionList(content)
-
-	for _, expr := range list {
-		if len(expr) > 0 && expr[0].Value != "," {
-			compiled := ce.CompileExpression(expr, "expressionList")
-			vmCode += compiled
-			counter++
-		}
+	for _, expression := range expressions {
+		vmCode += ce.CompileExpression(expression)
 	}
 
-	result = struct{ Code string; Args int }{vmCode, counter}
+	result = struct {
+		Code string
+		Args int
+	}{vmCode, len(expressions)}
 	return
-}
\ No newline at end of file
+}
@@ -0,0 +1,119 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CompileError is a problem at one place in a source file. Syntax errors
+// set Expected and Found; everything else uses Message. A warning is
+// reported but does not stop the output from being written.
+type CompileError struct {
+	Position   Position
+	Message    string
+	Expected   string
+	Found      string
+	SourceLine string
+	Warning    bool
+}
+
+func (e *CompileError) Error() string {
+	message := e.Message
+	if e.Expected != "" {
+		message = fmt.Sprintf("expected %s but found %s", e.Expected, e.Found)
+	}
+	if e.Warning {
+		message = "warning: " + message
+	}
+	if e.SourceLine == "" {
+		return fmt.Sprintf("%s: %s", e.Position, message)
+	}
+	return fmt.Sprintf("%s: %s\n%s\n%s", e.Position, message, e.SourceLine, caret(e.SourceLine, e.Position.Column))
+}
+
+// caret points at a column, copying tabs from the source line so the mark
+// lines up however wide the terminal shows them.
+func caret(line string, column int) string {
+	var marker strings.Builder
+	for i, char := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if char == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteRune(' ')
+		}
+	}
+	marker.WriteRune('^')
+	return marker.String()
+}
+
+type CompileErrors []*CompileError
+
+func (errs CompileErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	var counts []string
+	warnings := errs.Warnings()
+	if errors := len(errs) - warnings; errors > 0 {
+		counts = append(counts, plural(errors, "error"))
+	}
+	if warnings > 0 {
+		counts = append(counts, plural(warnings, "warning"))
+	}
+	return fmt.Sprintf("%s\n%s", strings.Join(messages, "\n\n"), strings.Join(counts, ", "))
+}
+
+func (errs CompileErrors) Warnings() int {
+	count := 0
+	for _, err := range errs {
+		if err.Warning {
+			count++
+		}
+	}
+	return count
+}
+
+func (errs CompileErrors) HasErrors() bool {
+	return errs.Warnings() < len(errs)
+}
+
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// withSource orders the errors by file and position and attaches the source
+// line each one points at.
+func (errs CompileErrors) withSource(files []ParsedContent) CompileErrors {
+	sources := make(map[string][]string)
+	for _, file := range files {
+		sources[file.Filename] = strings.Split(file.Source, "\n")
+	}
+
+	for _, err := range errs {
+		lines := sources[err.Position.File]
+		if line := err.Position.Line; line >= 1 && line <= len(lines) {
+			err.SourceLine = strings.TrimRight(lines[line-1], "\r")
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i].Position, errs[j].Position
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return errs
+}
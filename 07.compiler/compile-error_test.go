@@ -0,0 +1,50 @@
+package compiler
+
+import (
+	"testing"
+)
+
+// Errors from every file are reported together, in file and position order,
+// each with its source line and a caret that keeps the line's tabs.
+func TestCompileErrorsShowTheSource(t *testing.T) {
+	_, errs := compileClasses(t, map[string]string{
+		"Other.jack": "class Other {\n\tfunction int f() {\n\t\treturn y;\n\t}\n}\n",
+		"Main.jack": `class Main {
+    function void main() {
+        var int x;
+        let x = 1
+        return;
+    }
+}
+`,
+		"Bad.jack": "class Bad {\n    field int n = 1;\n}\n",
+	}, nil)
+
+	want := "Bad.jack:2:17: expected ',' or ';' but found '='\n" +
+		"    field int n = 1;\n" +
+		"                ^\n\n" +
+		"Main.jack:5:9: expected ';' but found 'return'\n" +
+		"        return;\n" +
+		"        ^\n\n" +
+		"Other.jack:3:10: undefined variable y\n" +
+		"\t\treturn y;\n" +
+		"\t\t       ^\n" +
+		"3 errors"
+	if errs == nil || errs.Error() != want {
+		t.Errorf("got:\n%v\nwant:\n%s", errs, want)
+	}
+}
+
+func TestCompileErrorCounts(t *testing.T) {
+	errs := CompileErrors{
+		{Position: Position{File: "A.jack", Line: 1, Column: 1}, Message: "one"},
+		{Position: Position{File: "A.jack", Line: 2, Column: 1}, Message: "two", Warning: true},
+	}
+	want := "A.jack:1:1: one\n\nA.jack:2:1: warning: two\n1 error, 1 warning"
+	if got := errs.Error(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if !errs.HasErrors() || errs.Warnings() != 1 {
+		t.Errorf("HasErrors() = %v, Warnings() = %d", errs.HasErrors(), errs.Warnings())
+	}
+}
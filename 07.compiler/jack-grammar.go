@@ -1,6 +1,4 @@
-package main
-
-import "regexp"
+package compiler
 
 var keyword = []string{
     "class",
@@ -31,12 +29,6 @@ var symbol = []string{
     "+", "-", "*", "/", "&", "|", "<", ">", "=", "~",
 }
 
-var integerConstant = regexp.MustCompile(`\d+`)
-
-var stringConstant = regexp.MustCompile(`".*"`)
-
-var identifier = regexp.MustCompile(`[a-zA-Z_]\w*`)
-
 var classVarDec = []string{"static", "field"}
 
 var types = []string{"int", "char", "boolean"}
@@ -49,25 +41,10 @@ var subroutineDec = []string{
 
 var statements = []string{"let", "if", "while", "do", "return"}
 
-var terms = []interface{}{
-    identifier,
-    integerConstant,
-    stringConstant,
-    "true",
-    "false",
-    "null",
-    "this",
-    "(",
-    "-",
-    "~",
-}
+var keywordConstants = []string{"true", "false", "null", "this"}
 
 var operands = []string{
     "+", "-", "*", "/", "&", "|", "<", ">", "=",
 }
 
-var unaryOperands = []string{"-", "~"}
-
-var operatingSystem = []string{
-    "Keyboard", "Screen", "Memory", "Output", "Sys",
-}
\ No newline at end of file
+var unaryOperands = []string{"-", "~"}
\ No newline at end of file
@@ -0,0 +1,156 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Lexer turns the source of one .jack file into tokens in a single pass
+// over its characters. Lines and columns start at 1; a tab counts as one
+// column.
+type Lexer struct {
+	file      string
+	source    []rune
+	offset    int
+	line      int
+	column    int
+	tokenizer *Tokenizer
+}
+
+func NewLexer(file, source string, tokenizer *Tokenizer) *Lexer {
+	return &Lexer{
+		file:      file,
+		source:    []rune(source),
+		line:      1,
+		column:    1,
+		tokenizer: tokenizer,
+	}
+}
+
+func (l *Lexer) position() Position {
+	return Position{File: l.file, Line: l.line, Column: l.column}
+}
+
+func (l *Lexer) peek(ahead int) rune {
+	if l.offset+ahead >= len(l.source) {
+		return 0
+	}
+	return l.source[l.offset+ahead]
+}
+
+func (l *Lexer) advance() rune {
+	char := l.source[l.offset]
+	l.offset++
+	if char == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return char
+}
+
+func (l *Lexer) Tokenize() ([]Tokenized, error) {
+	var tokens []Tokenized
+
+	for {
+		if err := l.skipSpaceAndComments(); err != nil {
+			return nil, err
+		}
+		if l.offset >= len(l.source) {
+			return tokens, nil
+		}
+
+		start := l.position()
+		lexeme, err := l.lexeme(start)
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := l.tokenizer.Tokenize(lexeme)
+		if err != nil {
+			return nil, &CompileError{Position: start, Message: err.Error()}
+		}
+		token.Position = start
+		tokens = append(tokens, token)
+	}
+}
+
+func (l *Lexer) skipSpaceAndComments() error {
+	for l.offset < len(l.source) {
+		switch char := l.peek(0); {
+		case char == ' ' || char == '\t' || char == '\r' || char == '\n':
+			l.advance()
+		case char == '/' && l.peek(1) == '/':
+			for l.offset < len(l.source) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		case char == '/' && l.peek(1) == '*':
+			start := l.position()
+			l.advance()
+			l.advance()
+			for !(l.peek(0) == '*' && l.peek(1) == '/') {
+				if l.offset >= len(l.source) {
+					return &CompileError{Position: start, Message: "unterminated comment"}
+				}
+				l.advance()
+			}
+			l.advance()
+			l.advance()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// lexeme reads the text of the token that starts at the current character.
+// The tokenizer then decides its type, so string constants keep their quotes
+// here and everything between them is taken exactly as written.
+func (l *Lexer) lexeme(start Position) (string, error) {
+	var text strings.Builder
+	char := l.peek(0)
+
+	switch {
+	case char == '"':
+		text.WriteRune(l.advance())
+		for l.peek(0) != '"' {
+			if l.offset >= len(l.source) || l.peek(0) == '\n' {
+				return "", &CompileError{Position: start, Message: "unterminated string constant"}
+			}
+			text.WriteRune(l.advance())
+		}
+		text.WriteRune(l.advance())
+	case isDigit(char):
+		for isDigit(l.peek(0)) {
+			text.WriteRune(l.advance())
+		}
+	case isLetter(char):
+		for isLetter(l.peek(0)) || isDigit(l.peek(0)) {
+			text.WriteRune(l.advance())
+		}
+	case contains(symbol, string(char)):
+		text.WriteRune(l.advance())
+	default:
+		return "", &CompileError{Position: start, Message: fmt.Sprintf("unexpected character %q", char)}
+	}
+	return text.String(), nil
+}
+
+func isDigit(char rune) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isLetter(char rune) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLexer(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		tokens []string
+		err    string
+	}{
+		{
+			name:   "symbols without spaces",
+			source: "a, b.",
+			tokens: []string{
+				"Main.jack:1:1 IDENTIFIER a",
+				"Main.jack:1:2 SYMBOL ,",
+				"Main.jack:1:4 IDENTIFIER b",
+				"Main.jack:1:5 SYMBOL .",
+			},
+		},
+		{
+			name:   "comment marker in a string",
+			source: "let s = \"a // b\"; // c",
+			tokens: []string{
+				"Main.jack:1:1 KEYWORD let",
+				"Main.jack:1:5 IDENTIFIER s",
+				"Main.jack:1:7 SYMBOL =",
+				"Main.jack:1:9 STRING_CONST a // b",
+				"Main.jack:1:17 SYMBOL ;",
+			},
+		},
+		{
+			name:   "block and doc comments",
+			source: "/** doc\n * more */ x /* a\nb */ y\n\t// end\n\tz",
+			tokens: []string{
+				"Main.jack:2:12 IDENTIFIER x",
+				"Main.jack:3:6 IDENTIFIER y",
+				"Main.jack:5:2 IDENTIFIER z",
+			},
+		},
+		{
+			name:   "largest integer",
+			source: "return 32767;",
+			tokens: []string{
+				"Main.jack:1:1 KEYWORD return",
+				"Main.jack:1:8 INT_CONST 32767",
+				"Main.jack:1:13 SYMBOL ;",
+			},
+		},
+		{
+			name:   "integer out of range",
+			source: "let x =\n  32768;",
+			err:    "Main.jack:2:3: integer constant 32768 is out of range 0..32767",
+		},
+		{
+			name:   "unterminated comment",
+			source: "x /* never closed",
+			err:    "Main.jack:1:3: unterminated comment",
+		},
+		{
+			name:   "unterminated string",
+			source: "let s = \"abc\n\";",
+			err:    "Main.jack:1:9: unterminated string constant",
+		},
+		{
+			name:   "unexpected character",
+			source: "let x = 1 # 2;",
+			err:    "Main.jack:1:11: unexpected character '#'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, err := NewLexer("Main.jack", test.source, NewTokenizer()).Tokenize()
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, token := range tokens {
+				got = append(got, fmt.Sprintf("%s %s %v", token.Position, token.Type, token.Value))
+			}
+			if strings.Join(got, "\n") != strings.Join(test.tokens, "\n") {
+				t.Errorf("got tokens:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(test.tokens, "\n"))
+			}
+		})
+	}
+}
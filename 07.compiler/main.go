@@ -1,17 +1,56 @@
-package main
+package compiler
 
 import (
-	"log"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
 )
 
-func main() {
-	currentDir, err := os.Getwd()
-    if err != nil {
-        log.Fatal(err)
-    }
-	
-	analyzer := NewAnalyzer(currentDir)
+// Main runs the compiler command line; see cmd/compiler.
+func Main() {
+	emit := flag.String("emit", "vm", "what to write for each class: tokens (xxxT.xml), xml (xxxT.xml and xxx.xml), ast (xxx.ast) or vm")
+	output := flag.String("o", "", "directory to write the output files to instead of next to their sources")
+	recursive := flag.Bool("r", false, "also compile the .jack files in subdirectories of directory arguments")
+	werror := flag.Bool("werror", false, "treat warnings as errors")
+	osDirectory := flag.String("os", "", "directory of OS .jack sources to resolve calls against instead of the built-in OS signatures")
+	typecheck := flag.Bool("typecheck", false, "run the type checker; its rules are reported as warnings unless set by -type-rules")
+	rules := flag.String("type-rules", "", "comma separated rule=error|warning|off settings for the type checker, rules: "+strings.Join(TypeRuleNames(), ", "))
+	layout := flag.Bool("layout", false, "print the field and static layout of every compiled class")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file.jack | directory]...\n\nCompiles the current directory when no files or directories are given.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	err = analyzer.Compile()
-}
\ No newline at end of file
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"."}
+	}
+
+	analyzer := NewAnalyzer(inputs)
+	analyzer.recursive = *recursive
+	analyzer.outputDirectory = *output
+	analyzer.werror = *werror
+	analyzer.osDirectory = *osDirectory
+	analyzer.layout = *layout
+
+	var err error
+	if *typecheck || *rules != "" {
+		analyzer.typeRules, err = ParseTypeRules(*rules)
+	}
+	if err == nil {
+		switch *emit {
+		case "vm":
+			err = analyzer.Compile()
+		case "tokens", "xml", "ast":
+			err = analyzer.Analyze(*emit)
+		default:
+			err = fmt.Errorf("unknown -emit %q, expected tokens, xml, ast or vm", *emit)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
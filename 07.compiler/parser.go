@@ -1,9 +1,10 @@
-package main
+package compiler
 
 import (
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
@@ -13,70 +14,62 @@ func NewParser() *Parser {
 	}
 }
 
-func (p *Parser) ReadFilesFromDirectory(directoryPath string) ([]ParsedContent, error) {
-	files, err := os.ReadDir(directoryPath)
-	if err != nil {
-		return nil, err
-	}
+// ReadFiles reads the .jack files among paths. A file is read as given, a
+// directory for the .jack files in it, or in all of its subdirectories when
+// recursive is set. Filenames keep the directory they were found in, and
+// two files of the same class are rejected since they would compile to the
+// same .vm file.
+func (p *Parser) ReadFiles(paths []string, recursive bool) ([]ParsedContent, error) {
+	found := map[string]string{}
 
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".jack" {
-			parsedContent := ParsedContent{
-				Filename: file.Name(),
-				Content:  []string{},
-			}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
 
-			filePath := filepath.Join(directoryPath, file.Name())
-			fileBytes, err := os.ReadFile(filePath)
-			if err != nil {
-				return nil, err
+		var files []string
+		switch {
+		case !info.IsDir():
+			if filepath.Ext(path) != ".jack" {
+				return nil, fmt.Errorf("%s: not a .jack file", path)
 			}
-			fileContent := string(fileBytes)
-
-			fileContent = p.preprocessContent(fileContent)
+			files = append(files, path)
+		case recursive:
+			err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() && filepath.Ext(file) == ".jack" {
+					files = append(files, file)
+				}
+				return err
+			})
+		default:
+			files, err = filepath.Glob(filepath.Join(path, "*.jack"))
+		}
+		if err != nil {
+			return nil, err
+		}
 
-			lines := strings.Split(fileContent, "\n")
-			for _, line := range lines {
-				trimmedLine := strings.TrimSpace(line)
-				if len(trimmedLine) > 0 {
-					parsedContent.Content = append(parsedContent.Content, trimmedLine)
+		for _, file := range files {
+			class := strings.TrimSuffix(filepath.Base(file), ".jack")
+			if previous, ok := found[class]; ok {
+				if filepath.Clean(previous) == filepath.Clean(file) {
+					continue
 				}
+				return nil, fmt.Errorf("%s: class %s is also in %s", file, class, previous)
 			}
+			found[class] = file
 
-			p.Content = append(p.Content, parsedContent)
+			fileBytes, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			p.Content = append(p.Content, ParsedContent{
+				Filename: file,
+				Source:   string(fileBytes),
+			})
 		}
 	}
 
 	return p.Content, nil
 }
-
-func (p *Parser) preprocessContent(fileContent string) string {
-	fileContent = regexp.MustCompile(`/\*[\s\S]*?\*/`).ReplaceAllString(fileContent, "")
-	
-	fileContent = regexp.MustCompile(`//.*`).ReplaceAllString(fileContent, "")
-
-	replacements := map[*regexp.Regexp]string{
-		regexp.MustCompile(`([,;().])`)       : " $1 ",
-		regexp.MustCompile(`\[(\d+)\]`)       : "[ $1 ]",
-		regexp.MustCompile(`(\w)(\[)`)        : "$1 $2",
-		regexp.MustCompile(`"([^"]*)"`)       : `" $1 "`,
-		regexp.MustCompile(`(~)(\w)`)         : "$1 $2", 
-		regexp.MustCompile(`(\w)(~)`)         : "$1 $2", 
-		regexp.MustCompile(`(\w)([-+])`)      : "$1 $2",
-		regexp.MustCompile(`([-+])(\w)`)      : "$1 $2",
-		regexp.MustCompile(`(\])(\w)`)        : "$1 $2",
-		regexp.MustCompile(`(\])(\[)`)        : "$1 $2",
-		regexp.MustCompile(`(\])`)            : "$1 ",
-		regexp.MustCompile(`(\[)([a-zA-Z])`)  : "$1 $2",
-		regexp.MustCompile(`([a-zA-Z])(\])`)  : "$1 $2",
-		regexp.MustCompile(`(\d)([^\d\s])`)   : "$1 $2",
-		regexp.MustCompile(`(\[)(\w+)(])`)    : "$1  $2  $3",
-		regexp.MustCompile(`([^\d\s])(\d)`)   : "$1 $2",
-	}
-
-	for regex, replacement := range replacements {
-		fileContent = regex.ReplaceAllString(fileContent, replacement)
-	}
-
-	return fileContent
-}
\ No newline at end of file
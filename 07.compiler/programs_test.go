@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compileClasses writes Jack classes into a new directory and compiles them
+// against the built-in OS signatures with warnings treated as errors. It
+// returns the commands of each .vm file without comments and blank lines,
+// or the diagnostics, with file names relative to the directory, when there
+// are any; then no file may have been written.
+func compileClasses(t *testing.T, files map[string]string, typeRules map[string]string) (map[string][]string, CompileErrors) {
+	t.Helper()
+	source, output := t.TempDir(), t.TempDir()
+	for name, code := range files {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analyzer := NewAnalyzer([]string{source})
+	analyzer.outputDirectory = output
+	analyzer.werror = true
+	analyzer.typeRules = typeRules
+	err := analyzer.Compile()
+	paths, globErr := filepath.Glob(filepath.Join(output, "*.vm"))
+	if globErr != nil {
+		t.Fatal(globErr)
+	}
+	if err != nil {
+		errs, ok := err.(CompileErrors)
+		if !ok {
+			t.Fatal(err)
+		}
+		if len(paths) > 0 {
+			t.Errorf("%d .vm files were written despite the diagnostics", len(paths))
+		}
+		for _, err := range errs {
+			err.Position.File = filepath.Base(err.Position.File)
+		}
+		return nil, errs
+	}
+
+	programs := make(map[string][]string)
+	for _, path := range paths {
+		code, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var commands []string
+		for _, line := range strings.Split(string(code), "\n") {
+			if line != "" && !strings.HasPrefix(line, "//") {
+				commands = append(commands, line)
+			}
+		}
+		programs[filepath.Base(path)] = commands
+	}
+	return programs, nil
+}
@@ -1,4 +1,9 @@
-package main
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
 
 func NewSymbolTable() *SymbolTable {
 	return &SymbolTable{
@@ -8,42 +13,62 @@ func NewSymbolTable() *SymbolTable {
 	}
 }
 
-func (st *SymbolTable) CreateClassTable(content [][]Tokenized) {
-	index := 0
+// CreateClassTable numbers statics and fields separately: fields are the
+// words of an object at this 0, 1, ..., and statics are the class's own
+// static 0, 1, ..., which the VM translator keeps apart per file.
+func (st *SymbolTable) CreateClassTable(varDecs []*ClassVarDec) {
+	st.ClassTable = []Table{}
+	indexes := map[string]int{}
+
+	for _, varDec := range varDecs {
+		for _, name := range varDec.Names {
+			st.ClassTable = append(st.ClassTable, Table{
+				Name:  name.Name,
+				Type:  varDec.Type,
+				Kind:  varDec.Kind,
+				Index: indexes[varDec.Kind],
+			})
+			indexes[varDec.Kind]++
+		}
+	}
+}
 
-	for _, line := range content {
-		commaIndex := findIndex(line, ",")
+// Count returns the number of class variables of a kind; for "field" it is
+// the size of an object in words.
+func (st *SymbolTable) Count(kind string) int {
+	count := 0
+	for _, entry := range st.ClassTable {
+		if entry.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
 
-		if commaIndex != -1 {
-			vars := filterTokens(line[2:len(line)-1], ",")
+// Layout describes where the variables of a class live, fields first.
+func (st *SymbolTable) Layout(className string) string {
+	var layout strings.Builder
+	fmt.Fprintf(&layout, "class %s: %s per object, %s\n",
+		className, plural(st.Count("field"), "word"), plural(st.Count("static"), "static"))
 
-			for _, token := range vars {
-				st.ClassTable = append(st.ClassTable, Table{
-					Name:  token.Value.(string),
-					Type:  line[1].Value.(string),
-					Kind:  line[0].Value.(string),
-					Index: index,
-				})
-				index++
+	for _, kind := range []string{"field", "static"} {
+		segment := map[string]string{"field": "this", "static": "static"}[kind]
+		for _, entry := range st.ClassTable {
+			if entry.Kind == kind {
+				fmt.Fprintf(&layout, "  %-10s %s %s %s\n",
+					fmt.Sprintf("%s %d", segment, entry.Index), kind, entry.Type, entry.Name)
 			}
-		} else {
-			st.ClassTable = append(st.ClassTable, Table{
-				Name:  line[2].Value.(string),
-				Type:  line[1].Value.(string),
-				Kind:  line[0].Value.(string),
-				Index: index,
-			})
-			index++
 		}
 	}
+	return layout.String()
 }
 
 func (st *SymbolTable) ResetSubroutineTable() {
 	st.SubroutineTable = []Table{}
 }
 
-func (st *SymbolTable) CreateSubroutineTable(content [][]Tokenized, t string, classType string) {	
-	if t == "method" {
+func (st *SymbolTable) CreateSubroutineTable(subroutine *Subroutine, classType string) {
+	if subroutine.Kind == "method" {
 		st.SubroutineTable = append(st.SubroutineTable, Table{
 			Name:  "this",
 			Type:  classType,
@@ -52,57 +77,45 @@ func (st *SymbolTable) CreateSubroutineTable(content [][]Tokenized, t string, cl
 		})
 	}
 
-	for _, line := range content {
-		commaIndex := findIndex(line, ",")
-		typeToken := findToken(line, types)
-		kind := "argument"
-		if line[0].Value == "var" {
-			kind = "var"
-		}
-	
-		if commaIndex != -1  {
-			var vars []Tokenized
-			
-			vars = filterTokens(line[2:len(line)-1], ",")
-			
-
-			for _, token := range vars {
-				kindTable := findLast(st.SubroutineTable, kind)
-				st.SubroutineTable = append(st.SubroutineTable, Table{
-					Name:  token.Value.(string),
-					Type:  typeToken.Value.(string),
-					Kind:  kind,
-					Index: kindTable.Index + 1,
-				})
-			}
-		} else {
-			kindTable := findLast(st.SubroutineTable, kind)
-			
-			var name interface{}
-			
-			if len(line) <= 2 {
-				name = line[1].Value
-			} else {
-				name = line[2].Value
-			}
-
-			st.SubroutineTable = append(st.SubroutineTable, Table{
-				Name:  name.(string),
-				Type:  typeToken.Value.(string),
-				Kind:  kind,
-				Index: kindTable.Index + 1,
-			})
+	for _, parameter := range subroutine.Parameters {
+		st.define(parameter.Name.Name, parameter.Type, "argument")
+	}
+	for _, varDec := range subroutine.VarDecs {
+		for _, name := range varDec.Names {
+			st.define(name.Name, varDec.Type, "var")
 		}
 	}
 }
 
-func (st *SymbolTable) CreateMethods(content [][]Tokenized) {
-	for index, item := range content {
+func (st *SymbolTable) define(name, varType, kind string) {
+	st.SubroutineTable = append(st.SubroutineTable, Table{
+		Name:  name,
+		Type:  varType,
+		Kind:  kind,
+		Index: findLast(st.SubroutineTable, kind).Index + 1,
+	})
+}
+
+func (st *SymbolTable) CreateMethods(subroutines []*Subroutine) {
+	st.Methods = []Table{}
+	for index, subroutine := range subroutines {
 		st.Methods = append(st.Methods, Table{
-			Name:  item[2].Value.(string),
-			Type:  item[1].Value.(string),
-			Kind:  item[0].Value.(string),
+			Name:  subroutine.Name,
+			Type:  subroutine.ReturnType,
+			Kind:  subroutine.Kind,
 			Index: index,
 		})
 	}
-}
\ No newline at end of file
+}
+
+// Lookup finds a variable in the subroutine scope first, then in the class.
+func (st *SymbolTable) Lookup(name string) (Table, bool) {
+	for _, table := range [][]Table{st.SubroutineTable, st.ClassTable} {
+		for _, entry := range table {
+			if entry.Name == name {
+				return entry, true
+			}
+		}
+	}
+	return Table{}, false
+}
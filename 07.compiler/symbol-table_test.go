@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+// Statics and fields are numbered separately however their declarations
+// are interleaved, and a constructor allocates one word per field only.
+func TestFieldsAfterStatics(t *testing.T) {
+	programs, errs := compileClasses(t, map[string]string{"Point.jack": `class Point {
+    static int count;
+    field int x;
+    static int total;
+    field int y;
+
+    constructor Point new(int ax) {
+        let x = ax;
+        let y = 2;
+        let count = count + 1;
+        let total = total + x;
+        return this;
+    }
+
+    method int getY() {
+        return y;
+    }
+}
+`}, nil)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	want := []string{
+		"function Point.new 0",
+		"push constant 2",
+		"call Memory.alloc 1",
+		"pop pointer 0",
+		"push argument 0",
+		"pop this 0",
+		"push constant 2",
+		"pop this 1",
+		"push static 0",
+		"push constant 1",
+		"add",
+		"pop static 0",
+		"push static 1",
+		"push this 0",
+		"add",
+		"pop static 1",
+		"push pointer 0",
+		"return",
+		"function Point.getY 0",
+		"push argument 0",
+		"pop pointer 0",
+		"push this 1",
+		"return",
+	}
+	if got := strings.Join(programs["Point.vm"], "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("Point.vm:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package compiler
 
 import (
 	"fmt"
@@ -23,8 +23,9 @@ const (
 )
 
 type Tokenized struct {
-	Type  TokenType
-	Value interface{}
+	Type     TokenType
+	Value    interface{}
+	Position Position
 }
 
 type Tokenizer struct{}
@@ -44,8 +45,6 @@ func (t *Tokenizer) TokenType(token string) (TokenType, error) {
 		return STRING_CONST, nil
 	} else if identifierRegex.MatchString(token) {
 		return IDENTIFIER, nil
-	} else if token == `"` {
-		return STRING_CONST, nil
 	}
 
 	return "", fmt.Errorf("invalid token: %s", token)
@@ -64,7 +63,11 @@ func (t *Tokenizer) Identifier(token string) string {
 }
 
 func (t *Tokenizer) IntVal(token string) (int, error) {
-	return strconv.Atoi(token)
+	value, err := strconv.Atoi(token)
+	if err != nil || value > 32767 {
+		return 0, fmt.Errorf("integer constant %s is out of range 0..32767", token)
+	}
+	return value, nil
 }
 
 func (t *Tokenizer) StringVal(token string) string {
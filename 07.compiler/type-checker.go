@@ -0,0 +1,273 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// typeRules are the checks of the type checker. Each one is reported as an
+// "error", a "warning" or is "off"; they all start as warnings because Jack
+// code commonly mixes ints and pointers.
+var typeRules = map[string]string{
+	"arguments":   "argument count and types against the callee's declaration",
+	"return":      "returned values against the subroutine's return type",
+	"void-value":  "void subroutines used as values",
+	"method-call": "methods called on variables of type int, char or boolean",
+	"operands":    "boolean operands mixed with int or char operands",
+}
+
+// ParseTypeRules reads a comma separated list such as
+// "arguments=error,operands=off" on top of the default of warnings.
+func ParseTypeRules(spec string) (map[string]string, error) {
+	rules := make(map[string]string)
+	for rule := range typeRules {
+		rules[rule] = "warning"
+	}
+	if spec == "" {
+		return rules, nil
+	}
+
+	for _, setting := range strings.Split(spec, ",") {
+		rule, level, ok := strings.Cut(setting, "=")
+		if _, known := typeRules[rule]; !ok || !known {
+			return nil, fmt.Errorf("unknown type rule %q, expected one of %s", setting, strings.Join(TypeRuleNames(), ", "))
+		}
+		if level != "error" && level != "warning" && level != "off" {
+			return nil, fmt.Errorf("type rule %s must be error, warning or off, not %q", rule, level)
+		}
+		rules[rule] = level
+	}
+	return rules, nil
+}
+
+func TypeRuleNames() []string {
+	var names []string
+	for rule := range typeRules {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckTypes checks a class against the declarations in the class index.
+// Calls that cannot be resolved are left to the code generator to report.
+func CheckTypes(class *Class, classIndex *ClassIndex, rules map[string]string) CompileErrors {
+	tc := &TypeChecker{rules: rules, classIndex: classIndex, class: class}
+
+	for _, subroutine := range class.Subroutines {
+		tc.subroutine = subroutine
+		tc.variables = make(map[string]string)
+		for _, varDec := range class.VarDecs {
+			for _, name := range varDec.Names {
+				tc.variables[name.Name] = varDec.Type
+			}
+		}
+		for _, parameter := range subroutine.Parameters {
+			tc.variables[parameter.Name.Name] = parameter.Type
+		}
+		for _, varDec := range subroutine.VarDecs {
+			for _, name := range varDec.Names {
+				tc.variables[name.Name] = varDec.Type
+			}
+		}
+		tc.statements(subroutine.Statements)
+	}
+	return tc.errors
+}
+
+func (tc *TypeChecker) report(rule string, position Position, format string, args ...any) {
+	level := tc.rules[rule]
+	if level == "off" {
+		return
+	}
+	tc.errors = append(tc.errors, &CompileError{
+		Position: position,
+		Message:  fmt.Sprintf(format, args...) + " [" + rule + "]",
+		Warning:  level != "error",
+	})
+}
+
+// compatible tells whether a value of type source can be used where target
+// is declared. An empty type is unknown and matches anything, int and char
+// are both numbers, null is any object, and Array is an untyped pointer
+// that also stands for int.
+func compatible(target, source string) bool {
+	switch {
+	case target == source || target == "" || source == "":
+		return true
+	case isNumber(target) && isNumber(source):
+		return true
+	case source == "null":
+		return !contains(types, target)
+	case target == "Array" || source == "Array":
+		return target == "int" || source == "int" || (!contains(types, target) && !contains(types, source))
+	}
+	return false
+}
+
+func isNumber(name string) bool {
+	return name == "int" || name == "char"
+}
+
+func (tc *TypeChecker) statements(statements []Statement) {
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *LetStatement:
+			if s.Index != nil {
+				tc.expression(s.Index)
+			}
+			tc.expression(s.Value)
+		case *IfStatement:
+			tc.expression(s.Condition)
+			tc.statements(s.Then)
+			tc.statements(s.Else)
+		case *WhileStatement:
+			tc.expression(s.Condition)
+			tc.statements(s.Body)
+		case *DoStatement:
+			tc.call(s.Call)
+		case *ReturnStatement:
+			tc.checkReturn(s)
+		}
+	}
+}
+
+func (tc *TypeChecker) checkReturn(statement *ReturnStatement) {
+	declared := tc.subroutine.ReturnType
+	if statement.Value == nil {
+		if declared != "void" {
+			tc.report("return", statement.Position, "%s must return %s %s", tc.subroutine.Name, article(declared), declared)
+		}
+		return
+	}
+
+	valueType := tc.expression(statement.Value)
+	if declared == "void" {
+		tc.report("return", statement.Value.Position, "void %s returns a value", tc.subroutine.Name)
+	} else if !compatible(declared, valueType) {
+		tc.report("return", statement.Value.Position, "%s returns %s, declared %s", tc.subroutine.Name, valueType, declared)
+	}
+}
+
+// expression returns the type of an expression. Jack evaluates the
+// operators from left to right, so the type is carried along the same way.
+func (tc *TypeChecker) expression(expression *Expression) string {
+	result := tc.term(expression.Terms[0])
+	for i, operator := range expression.Operators {
+		right := tc.term(expression.Terms[i+1])
+		position := expression.Terms[i+1].Pos()
+
+		switch operator {
+		case "+", "-", "*", "/", "<", ">":
+			if result == "boolean" || right == "boolean" {
+				tc.report("operands", position, "boolean operand of %s", operator)
+			}
+			result = "int"
+			if operator == "<" || operator == ">" {
+				result = "boolean"
+			}
+		case "&", "|":
+			if (result == "boolean") != (right == "boolean") && result != "" && right != "" {
+				tc.report("operands", position, "%s mixes %s and %s", operator, result, right)
+			}
+			if result != "boolean" || right != "boolean" {
+				result = "int"
+			}
+		case "=":
+			if (result == "boolean") != (right == "boolean") && result != "" && right != "" {
+				tc.report("operands", position, "= compares %s with %s", result, right)
+			}
+			result = "boolean"
+		}
+	}
+	return result
+}
+
+func (tc *TypeChecker) term(term Term) string {
+	switch t := term.(type) {
+	case *IntegerConstant:
+		return "int"
+	case *StringConstant:
+		return "String"
+	case *KeywordConstant:
+		switch t.Value {
+		case "true", "false":
+			return "boolean"
+		case "this":
+			return tc.class.Name
+		}
+		return "null"
+	case *VarTerm:
+		if t.Index != nil {
+			tc.expression(t.Index)
+			return ""
+		}
+		return tc.variables[t.Name]
+	case *SubroutineCall:
+		returnType := tc.call(t)
+		if returnType == "void" {
+			tc.report("void-value", t.Position, "void %s is used as a value", t.Name)
+			return ""
+		}
+		return returnType
+	case *ParenTerm:
+		return tc.expression(t.Expression)
+	case *UnaryTerm:
+		operand := tc.term(t.Term)
+		if t.Operator == "-" {
+			if operand == "boolean" {
+				tc.report("operands", t.Position, "- of a boolean")
+			}
+			return "int"
+		}
+		return operand
+	}
+	return ""
+}
+
+// call checks a call against its declaration and returns the declared
+// return type, or an empty type when the callee is unknown.
+func (tc *TypeChecker) call(call *SubroutineCall) string {
+	argumentTypes := make([]string, len(call.Arguments))
+	for i, argument := range call.Arguments {
+		argumentTypes[i] = tc.expression(argument)
+	}
+
+	className := tc.class.Name
+	if call.Receiver != "" {
+		className = call.Receiver
+		if variableType, ok := tc.variables[call.Receiver]; ok {
+			if contains(types, variableType) {
+				tc.report("method-call", call.Position, "%s is %s %s, methods can only be called on objects", call.Receiver, article(variableType), variableType)
+				return ""
+			}
+			className = variableType
+		}
+	}
+
+	subroutine, ok := tc.classIndex.Subroutine(className, call.Name)
+	if !ok {
+		return ""
+	}
+
+	if len(call.Arguments) != len(subroutine.Parameters) {
+		tc.report("arguments", call.Position, "%s.%s takes %d arguments, called with %d",
+			className, call.Name, len(subroutine.Parameters), len(call.Arguments))
+	} else {
+		for i, parameter := range subroutine.Parameters {
+			if !compatible(parameter.Type, argumentTypes[i]) {
+				tc.report("arguments", call.Arguments[i].Position, "argument %s of %s.%s is %s, declared %s",
+					parameter.Name.Name, className, call.Name, argumentTypes[i], parameter.Type)
+			}
+		}
+	}
+	return subroutine.ReturnType
+}
+
+func article(name string) string {
+	if strings.ContainsRune("aeiou", rune(name[0])) {
+		return "an"
+	}
+	return "a"
+}
@@ -0,0 +1,83 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+// typedMain breaks every type rule once.
+const typedMain = `class Main {
+    function int twice(int n) {
+        return n + n;
+    }
+
+    function void nothing() {
+        return;
+    }
+
+    function boolean main() {
+        var int i;
+        var boolean b;
+        let i = Main.twice(true);
+        let i = Main.twice(1, 2);
+        let i = Main.nothing();
+        do i.draw();
+        let b = (i < 3) + 1;
+        let b = b & i;
+        return i;
+    }
+}
+`
+
+func TestTypeRules(t *testing.T) {
+	all := []string{
+		"13:28: warning: argument n of Main.twice is boolean, declared int [arguments]",
+		"14:17: warning: Main.twice takes 1 arguments, called with 2 [arguments]",
+		"15:17: warning: void nothing is used as a value [void-value]",
+		"16:12: warning: i is an int, methods can only be called on objects [method-call]",
+		"17:27: warning: boolean operand of + [operands]",
+		"18:21: warning: & mixes boolean and int [operands]",
+		"19:16: warning: main returns int, declared boolean [return]",
+	}
+
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"", all},
+		{
+			"arguments=error,operands=off,return=off",
+			[]string{
+				"13:28: argument n of Main.twice is boolean, declared int [arguments]",
+				"14:17: Main.twice takes 1 arguments, called with 2 [arguments]",
+				"15:17: warning: void nothing is used as a value [void-value]",
+				"16:12: warning: i is an int, methods can only be called on objects [method-call]",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := ParseTypeRules(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, errs := compileClasses(t, map[string]string{"Main.jack": typedMain}, rules)
+		got := diagnostics(errs)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("-type-rules %q, got:\n%s\nwant:\n%s", test.spec, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+
+	// Without -typecheck the type rules do not run at all.
+	if _, errs := compileClasses(t, map[string]string{"Main.jack": typedMain}, nil); errs != nil {
+		t.Errorf("without type rules, got:\n%v", errs)
+	}
+}
+
+func TestParseTypeRules(t *testing.T) {
+	for _, spec := range []string{"arguments", "unknown=error", "return=fatal"} {
+		if _, err := ParseTypeRules(spec); err == nil {
+			t.Errorf("ParseTypeRules(%q) accepted an invalid setting", spec)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package compiler
 
 type TokenizedFile struct {
 	Filename string
@@ -7,11 +7,19 @@ type TokenizedFile struct {
 
 type Analyzer struct {
 	parsedContent     []ParsedContent
-	directoryPath     string
+	inputs            []string
+	recursive         bool
+	outputDirectory   string
 	parser            *Parser
 	tokenizer         *Tokenizer
 	tokenizedFiles    []TokenizedFile
 	compilationEngine *CompilationEngine
+	osDirectory       string
+	osContent         []ParsedContent
+	typeRules         map[string]string
+	layout            bool
+	werror            bool
+	errors            CompileErrors
 }
 
 type Table struct {
@@ -28,28 +36,51 @@ type CodeWriter struct {
 
 type CompilationEngine struct {
 	tokenizedFiles   []TokenizedFile
-	divider          *Divider
 	symbolTable      *SymbolTable
 	codeWriter       *CodeWriter
 	className        string
+	subroutineKind   string
+	classIndex       *ClassIndex
+	typeRules        map[string]string
+	layouts          []string
+	unparsed         map[string]bool
+	errors           CompileErrors
 }
 
-type IfStatements struct {
-	If   [][]Tokenized
-	Else [][]Tokenized
+type OutputFile struct {
+	Filename string
+	Code     string
 }
 
-type Divider struct{}
-
 type ParsedContent struct {
 	Filename string
-	Content  []string
+	Source   string
 }
 
 type Parser struct {
 	Content []ParsedContent
 }
 
+type ClassIndex struct {
+	classes map[string]map[string]*Subroutine
+}
+
+type Checker struct {
+	errors      CompileErrors
+	classVars   map[string]*symbolUse
+	locals      map[string]*symbolUse
+	subroutines map[string]Position
+}
+
+type TypeChecker struct {
+	errors     CompileErrors
+	rules      map[string]string
+	classIndex *ClassIndex
+	class      *Class
+	subroutine *Subroutine
+	variables  map[string]string
+}
+
 type SymbolTable struct {
 	ClassTable      []Table
 	SubroutineTable []Table
@@ -0,0 +1,251 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+var tokenElements = map[TokenType]string{
+	KEYWORD:      "keyword",
+	SYMBOL:       "symbol",
+	IDENTIFIER:   "identifier",
+	INT_CONST:    "integerConstant",
+	STRING_CONST: "stringConstant",
+}
+
+// XMLWriter writes the token and parse tree files of nand2tetris project
+// 10: one element per line, two spaces of indentation per level, and the
+// terminal's text padded with a space on each side.
+type XMLWriter struct {
+	builder strings.Builder
+	depth   int
+}
+
+func (w *XMLWriter) line(text string) {
+	w.builder.WriteString(strings.Repeat("  ", w.depth) + text + "\n")
+}
+
+func (w *XMLWriter) open(tag string) {
+	w.line("<" + tag + ">")
+	w.depth++
+}
+
+func (w *XMLWriter) close(tag string) {
+	w.depth--
+	w.line("</" + tag + ">")
+}
+
+func (w *XMLWriter) terminal(element, text string) {
+	w.line(fmt.Sprintf("<%s> %s </%s>", element, xmlEscaper.Replace(text), element))
+}
+
+func (w *XMLWriter) keyword(text string)    { w.terminal("keyword", text) }
+func (w *XMLWriter) symbol(text string)     { w.terminal("symbol", text) }
+func (w *XMLWriter) identifier(text string) { w.terminal("identifier", text) }
+
+func (w *XMLWriter) typeName(name string) {
+	if contains(types, name) || name == "void" {
+		w.keyword(name)
+	} else {
+		w.identifier(name)
+	}
+}
+
+func TokensXML(tokens []Tokenized) string {
+	w := &XMLWriter{}
+	w.line("<tokens>")
+	for _, token := range tokens {
+		text := tokenText(token)
+		if token.Type == STRING_CONST {
+			text = token.Value.(string)
+		}
+		w.terminal(tokenElements[token.Type], text)
+	}
+	w.line("</tokens>")
+	return w.builder.String()
+}
+
+func ClassXML(class *Class) string {
+	w := &XMLWriter{}
+	w.open("class")
+	w.keyword("class")
+	w.identifier(class.Name)
+	w.symbol("{")
+	for _, varDec := range class.VarDecs {
+		w.open("classVarDec")
+		w.keyword(varDec.Kind)
+		w.typeName(varDec.Type)
+		w.names(varDec.Names)
+		w.close("classVarDec")
+	}
+	for _, subroutine := range class.Subroutines {
+		w.subroutine(subroutine)
+	}
+	w.symbol("}")
+	w.close("class")
+	return w.builder.String()
+}
+
+func (w *XMLWriter) names(names []VarName) {
+	for i, name := range names {
+		if i > 0 {
+			w.symbol(",")
+		}
+		w.identifier(name.Name)
+	}
+	w.symbol(";")
+}
+
+func (w *XMLWriter) subroutine(subroutine *Subroutine) {
+	w.open("subroutineDec")
+	w.keyword(subroutine.Kind)
+	w.typeName(subroutine.ReturnType)
+	w.identifier(subroutine.Name)
+	w.symbol("(")
+	w.open("parameterList")
+	for i, parameter := range subroutine.Parameters {
+		if i > 0 {
+			w.symbol(",")
+		}
+		w.typeName(parameter.Type)
+		w.identifier(parameter.Name.Name)
+	}
+	w.close("parameterList")
+	w.symbol(")")
+
+	w.open("subroutineBody")
+	w.symbol("{")
+	for _, varDec := range subroutine.VarDecs {
+		w.open("varDec")
+		w.keyword("var")
+		w.typeName(varDec.Type)
+		w.names(varDec.Names)
+		w.close("varDec")
+	}
+	w.statements(subroutine.Statements)
+	w.symbol("}")
+	w.close("subroutineBody")
+	w.close("subroutineDec")
+}
+
+func (w *XMLWriter) block(statements []Statement) {
+	w.symbol("{")
+	w.statements(statements)
+	w.symbol("}")
+}
+
+func (w *XMLWriter) statements(statements []Statement) {
+	w.open("statements")
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *LetStatement:
+			w.open("letStatement")
+			w.keyword("let")
+			w.identifier(s.Name.Name)
+			if s.Index != nil {
+				w.symbol("[")
+				w.expression(s.Index)
+				w.symbol("]")
+			}
+			w.symbol("=")
+			w.expression(s.Value)
+			w.symbol(";")
+			w.close("letStatement")
+		case *IfStatement:
+			w.open("ifStatement")
+			w.keyword("if")
+			w.condition(s.Condition)
+			w.block(s.Then)
+			if s.HasElse {
+				w.keyword("else")
+				w.block(s.Else)
+			}
+			w.close("ifStatement")
+		case *WhileStatement:
+			w.open("whileStatement")
+			w.keyword("while")
+			w.condition(s.Condition)
+			w.block(s.Body)
+			w.close("whileStatement")
+		case *DoStatement:
+			w.open("doStatement")
+			w.keyword("do")
+			w.call(s.Call)
+			w.symbol(";")
+			w.close("doStatement")
+		case *ReturnStatement:
+			w.open("returnStatement")
+			w.keyword("return")
+			if s.Value != nil {
+				w.expression(s.Value)
+			}
+			w.symbol(";")
+			w.close("returnStatement")
+		}
+	}
+	w.close("statements")
+}
+
+func (w *XMLWriter) condition(expression *Expression) {
+	w.symbol("(")
+	w.expression(expression)
+	w.symbol(")")
+}
+
+func (w *XMLWriter) expression(expression *Expression) {
+	w.open("expression")
+	w.term(expression.Terms[0])
+	for i, operator := range expression.Operators {
+		w.symbol(operator)
+		w.term(expression.Terms[i+1])
+	}
+	w.close("expression")
+}
+
+func (w *XMLWriter) term(term Term) {
+	w.open("term")
+	switch t := term.(type) {
+	case *IntegerConstant:
+		w.terminal("integerConstant", fmt.Sprint(t.Value))
+	case *StringConstant:
+		w.terminal("stringConstant", t.Value)
+	case *KeywordConstant:
+		w.keyword(t.Value)
+	case *VarTerm:
+		w.identifier(t.Name)
+		if t.Index != nil {
+			w.symbol("[")
+			w.expression(t.Index)
+			w.symbol("]")
+		}
+	case *SubroutineCall:
+		w.call(t)
+	case *ParenTerm:
+		w.condition(t.Expression)
+	case *UnaryTerm:
+		w.symbol(t.Operator)
+		w.term(t.Term)
+	}
+	w.close("term")
+}
+
+// call writes a subroutine call inline; project 10 has no element for it.
+func (w *XMLWriter) call(call *SubroutineCall) {
+	if call.Receiver != "" {
+		w.identifier(call.Receiver)
+		w.symbol(".")
+	}
+	w.identifier(call.Name)
+	w.symbol("(")
+	w.open("expressionList")
+	for i, argument := range call.Arguments {
+		if i > 0 {
+			w.symbol(",")
+		}
+		w.expression(argument)
+	}
+	w.close("expressionList")
+	w.symbol(")")
+}
@@ -0,0 +1,36 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The token and parse tree files of testdata/xml must match the fixtures
+// next to the sources, which follow the project 10 comparison files.
+func TestXMLGolden(t *testing.T) {
+	directory := t.TempDir()
+	analyzer := NewAnalyzer([]string{filepath.Join("testdata", "xml")})
+	analyzer.outputDirectory = directory
+	if err := analyzer.Analyze("xml"); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "xml", "*.xml"))
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("no fixtures in testdata/xml: %v", err)
+	}
+	for _, fixture := range fixtures {
+		want, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(directory, filepath.Base(fixture)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s differs from the fixture:\n%s", filepath.Base(fixture), got)
+		}
+	}
+}
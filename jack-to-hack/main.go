@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	assembler "nand2tetris/05.assembler"
+	vmtranslator "nand2tetris/06.virtual-machine"
+	compiler "nand2tetris/07.compiler"
+)
+
+// A build runs the three stages on the same program: the compiler writes a
+// .vm file per class into directory, the VM translator turns them into one
+// .asm program with the bootstrap code, and the assembler writes the .hack
+// file.
+type build struct {
+	inputs      []string
+	recursive   bool
+	osDirectory string
+	directory   string
+	output      string
+	werror      bool
+}
+
+// stageError stops the pipeline; the error of the stage already carries the
+// source position.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.stage, e.err)
+}
+
+func (b *build) run() error {
+	inputs := b.inputs
+	if b.osDirectory != "" {
+		files, err := osFiles(b.osDirectory, b.inputs, b.recursive)
+		if err != nil {
+			return &stageError{"compile", err}
+		}
+		inputs = append(inputs, files...)
+	}
+
+	err := compiler.Build(compiler.Options{
+		Inputs:          inputs,
+		Recursive:       b.recursive,
+		OutputDirectory: b.directory,
+		OSDirectory:     b.osDirectory,
+		Werror:          b.werror,
+	})
+	if err != nil {
+		return &stageError{"compile", err}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(b.output), ".hack")
+	asm, err := vmtranslator.Translate(b.directory)
+	if err != nil {
+		return &stageError{"translate", err}
+	}
+	asmFile := filepath.Join(b.directory, name+".asm")
+	if err := os.WriteFile(asmFile, []byte(asm), 0644); err != nil {
+		return &stageError{"translate", err}
+	}
+
+	hack, err := assembler.Assemble(asmFile, asm)
+	if err != nil {
+		return &stageError{"assemble", err}
+	}
+	if err := os.MkdirAll(filepath.Dir(b.output), 0755); err != nil {
+		return &stageError{"assemble", err}
+	}
+	if err := os.WriteFile(b.output, []byte(hack), 0644); err != nil {
+		return &stageError{"assemble", err}
+	}
+	return nil
+}
+
+// manifest lists the files a build wrote into a kept directory, so the next
+// build removes exactly those and nothing that was already there.
+const manifest = ".jack-to-hack"
+
+// prepare settles the output file and the directory for the intermediate
+// files. A kept directory must be new, empty or one an earlier build used;
+// the files that build wrote are removed so they are not linked into this
+// one, and any other .vm file stops the build for the same reason.
+func (b *build) prepare() error {
+	var err error
+	if b.output == "" {
+		if b.output, err = outputName(b.inputs[0]); err != nil {
+			return err
+		}
+	}
+
+	if b.directory == "" {
+		b.directory, err = os.MkdirTemp("", "jack-to-hack")
+		return err
+	}
+	if err := os.MkdirAll(b.directory, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(b.directory)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	written, err := os.ReadFile(filepath.Join(b.directory, manifest))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s: -keep directory is not empty and was not written by an earlier build", b.directory)
+	} else if err != nil {
+		return err
+	}
+	for _, name := range strings.Fields(string(written)) {
+		if err := os.Remove(filepath.Join(b.directory, filepath.Base(name))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	stale, err := filepath.Glob(filepath.Join(b.directory, "*.vm"))
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("%s: -keep directory has .vm files an earlier build did not write, such as %s", b.directory, filepath.Base(stale[0]))
+	}
+	return nil
+}
+
+// record writes the manifest of a kept directory: every .vm file in it,
+// since prepare left none behind, and the .asm file.
+func (b *build) record() error {
+	written, err := filepath.Glob(filepath.Join(b.directory, "*.vm"))
+	if err != nil {
+		return err
+	}
+	written = append(written, strings.TrimSuffix(filepath.Base(b.output), ".hack")+".asm")
+
+	var names strings.Builder
+	for _, file := range written {
+		names.WriteString(filepath.Base(file) + "\n")
+	}
+	return os.WriteFile(filepath.Join(b.directory, manifest), []byte(names.String()), 0644)
+}
+
+// osFiles lists the OS sources the program does not replace with a class of
+// its own, so a program can bring, say, its own Math.jack.
+func osFiles(osDirectory string, inputs []string, recursive bool) ([]string, error) {
+	sources, err := compiler.NewParser().ReadFiles(inputs, recursive)
+	if err != nil {
+		return nil, err
+	}
+	replaced := map[string]bool{}
+	for _, source := range sources {
+		replaced[filepath.Base(source.Filename)] = true
+	}
+
+	files, err := filepath.Glob(filepath.Join(osDirectory, "*.jack"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no .jack files", osDirectory)
+	}
+
+	var kept []string
+	for _, file := range files {
+		if !replaced[filepath.Base(file)] {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// outputName names the .hack file after the first input: a directory gives
+// its own name inside it, a file its name with .hack.
+func outputName(input string) (string, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return strings.TrimSuffix(input, ".jack") + ".hack", nil
+	}
+	path, err := filepath.Abs(input)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(input, filepath.Base(path)+".hack"), nil
+}
+
+func main() {
+	output := flag.String("o", "", "the .hack file to write; by default it is named after the first input")
+	osDirectory := flag.String("os", "", "directory of the OS .jack sources to compile into the program, e.g. 08.OS")
+	keep := flag.String("keep", "", "directory to keep the intermediate .vm and .asm files in; they are deleted otherwise")
+	recursive := flag.Bool("r", false, "also compile the .jack files in subdirectories of directory arguments")
+	werror := flag.Bool("werror", false, "treat compiler warnings as errors")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file.jack | directory]...\n\nBuilds the current directory when no files or directories are given.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	b := &build{
+		inputs:      flag.Args(),
+		recursive:   *recursive,
+		osDirectory: *osDirectory,
+		directory:   *keep,
+		output:      *output,
+		werror:      *werror,
+	}
+	if len(b.inputs) == 0 {
+		b.inputs = []string{"."}
+	}
+
+	if err := b.prepare(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err := b.run()
+	if *keep == "" {
+		os.RemoveAll(b.directory)
+	} else if recordErr := b.record(); err == nil {
+		err = recordErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}